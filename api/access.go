@@ -0,0 +1,339 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+	"github.com/roGal1k/golang-beginner/internal/slug"
+)
+
+var (
+	// Объект не существует или недоступен пользователю.
+	// Оба случая неразличимы для клиента, чтобы не раскрывать чужие данные.
+	errNotFound = errors.New("not found")
+	// Объект доступен пользователю, но его роли недостаточно для действия
+	errForbidden = errors.New("insufficient project role")
+)
+
+// Проекты и шаблоны, доступные пользователю: личные, те, где он участник,
+// и проекты его организаций (все - для владельцев и администраторов,
+// с видимостью organization - для остальных участников)
+func (a *API) accessibleProjects(user *model.User) *gorm.DB {
+	return a.DB.Model(&model.Project{}).Where("(projects.organization_id IS NULL AND projects.user_id = ?) OR EXISTS (?) OR EXISTS (?)",
+		user.ID,
+		a.DB.Model(&model.ProjectMember{}).Select("1").
+			Where("project_members.project_id = projects.id AND project_members.user_id = ?", user.ID),
+		a.DB.Model(&model.OrganizationMember{}).Select("1").
+			Where("organization_members.organization_id = projects.organization_id AND organization_members.user_id = ?", user.ID).
+			Where("(organization_members.role IN ? OR projects.visibility = ?)",
+				[]string{model.OrgRoleOwner, model.OrgRoleAdmin}, model.VisibilityOrganization))
+}
+
+// Разрешение проекта или шаблона по адресу среди доступных пользователю
+// с проверкой роли. Проект, найденный по прежнему адресу или по названию,
+// возвращается с ошибкой slugMovedError для перенаправления.
+func (a *API) resolveProject(user *model.User, projectSlug string, template bool, minRole string) (*model.Project, error) {
+	moved := false
+	project, err := a.findProject(user, template, "projects.slug = ?", projectSlug)
+	if errors.Is(err, errNotFound) {
+		// Прежний адрес переименованного проекта
+		aliases := a.DB.Model(&model.SlugAlias{}).Select("target_id").
+			Where("kind = ? AND slug = ?", model.SlugKindProject, projectSlug)
+		project, err = a.findProject(user, template, "projects.id IN (?)", aliases)
+		moved = err == nil
+	}
+	if errors.Is(err, errNotFound) {
+		// Название вместо адреса
+		if normalized := slug.Make(projectSlug); normalized != "" && normalized != projectSlug {
+			project, err = a.findProject(user, template, "projects.slug = ?", normalized)
+			moved = err == nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := a.projectRole(user, project)
+	if err != nil {
+		return nil, err
+	}
+	if projectRoleRank(role) < projectRoleRank(minRole) {
+		return nil, errForbidden
+	}
+	if moved {
+		return project, &slugMovedError{slug: project.Slug}
+	}
+	return project, nil
+}
+
+// Поиск проекта среди доступных пользователю. При совпадении
+// адресов личный проект имеет приоритет.
+func (a *API) findProject(user *model.User, template bool, query string, args ...interface{}) (*model.Project, error) {
+	var project model.Project
+	err := a.accessibleProjects(user).Where("projects.is_template = ?", template).Where(query, args...).
+		Order(clause.Expr{SQL: "(projects.organization_id IS NULL AND projects.user_id = ?) DESC", Vars: []interface{}{user.ID}}).
+		Order("projects.id").
+		First(&project).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// Роль пользователя в проекте; пустая строка - нет доступа
+func (a *API) projectRole(user *model.User, project *model.Project) (string, error) {
+	return projectRoleTx(a.DB, user, project)
+}
+
+// Роль пользователя в проекте в рамках транзакции tx
+func projectRoleTx(tx *gorm.DB, user *model.User, project *model.Project) (string, error) {
+	var memberRole, orgRole string
+
+	var member model.ProjectMember
+	err := tx.Where("project_id = ? AND user_id = ?", project.ID, user.ID).First(&member).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	memberRole = member.Role
+
+	if project.OrganizationID != nil {
+		orgRole, err = organizationRoleTx(tx, user.ID, *project.OrganizationID)
+		if err != nil {
+			return "", err
+		}
+	}
+	return effectiveProjectRole(user.ID, project, memberRole, orgRole), nil
+}
+
+// Роли пользователя во всех доступных проектах, загруженные одним набором
+// запросов: для списков проектов
+func (a *API) projectRoleLookup(user *model.User) (func(project *model.Project) string, error) {
+	var memberships []model.ProjectMember
+	err := a.DB.Where("user_id = ?", user.ID).Find(&memberships).Error
+	if err != nil {
+		return nil, err
+	}
+	memberRoles := make(map[uint]string, len(memberships))
+	for _, member := range memberships {
+		memberRoles[member.ProjectID] = member.Role
+	}
+
+	var orgMemberships []model.OrganizationMember
+	err = a.DB.Where("user_id = ?", user.ID).Find(&orgMemberships).Error
+	if err != nil {
+		return nil, err
+	}
+	orgRoles := make(map[uint]string, len(orgMemberships))
+	for _, member := range orgMemberships {
+		orgRoles[member.OrganizationID] = member.Role
+	}
+
+	return func(project *model.Project) string {
+		var orgRole string
+		if project.OrganizationID != nil {
+			orgRole = orgRoles[*project.OrganizationID]
+		}
+		return effectiveProjectRole(user.ID, project, memberRoles[project.ID], orgRole)
+	}, nil
+}
+
+// Итоговая роль в проекте: наибольшая из роли участника проекта
+// и роли, следующей из владения проектом или участия в организации
+func effectiveProjectRole(userID uint, project *model.Project, memberRole, orgRole string) string {
+	role := memberRole
+	grant := func(candidate string) {
+		if projectRoleRank(candidate) > projectRoleRank(role) {
+			role = candidate
+		}
+	}
+
+	if project.OrganizationID == nil {
+		if project.UserID == userID {
+			grant(model.ProjectRoleOwner)
+		}
+		return role
+	}
+
+	switch {
+	case orgRoleRank(orgRole) >= orgRoleRank(model.OrgRoleAdmin):
+		grant(model.ProjectRoleOwner)
+	case orgRole != "" && project.Visibility == model.VisibilityOrganization:
+		grant(model.ProjectRoleViewer)
+	}
+	return role
+}
+
+// Порядок ролей проекта: каждая следующая включает права предыдущих
+func projectRoleRank(role string) int {
+	switch role {
+	case model.ProjectRoleViewer:
+		return 1
+	case model.ProjectRoleEditor:
+		return 2
+	case model.ProjectRoleOwner:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Разрешение раздела внутри проекта по адресу. Раздел, найденный
+// по прежнему адресу или по названию, возвращается с ошибкой slugMovedError.
+func (a *API) resolveSection(project *model.Project, sectionSlug string) (*model.Section, error) {
+	section, err := a.findSection(project, "slug = ?", sectionSlug)
+	if !errors.Is(err, errNotFound) {
+		return section, err
+	}
+
+	// Прежний адрес переименованного раздела
+	aliases := a.DB.Model(&model.SlugAlias{}).Select("target_id").
+		Where("kind = ? AND parent_id = ? AND slug = ?", model.SlugKindSection, project.ID, sectionSlug)
+	section, err = a.findSection(project, "id IN (?)", aliases)
+	if errors.Is(err, errNotFound) {
+		// Название вместо адреса
+		normalized := slug.Make(sectionSlug)
+		if normalized == "" || normalized == sectionSlug {
+			return nil, errNotFound
+		}
+		section, err = a.findSection(project, "slug = ?", normalized)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return section, &slugMovedError{slug: section.Slug}
+}
+
+func (a *API) findSection(project *model.Project, query string, args ...interface{}) (*model.Section, error) {
+	var section model.Section
+	err := a.DB.Where("project_id = ?", project.ID).Where(query, args...).Order("id DESC").First(&section).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &section, nil
+}
+
+// Разрешение содержимого внутри раздела
+func (a *API) resolveContent(section *model.Section, contentID string) (*model.Content, error) {
+	id, err := strconv.ParseUint(contentID, 10, 64)
+	if err != nil {
+		return nil, errNotFound
+	}
+
+	var content model.Content
+	err = a.DB.Where("id = ? AND section_id = ?", id, section.ID).First(&content).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &content, nil
+}
+
+// Значение переменной пути (mux сопоставляет уже декодированный путь)
+func pathVar(r *http.Request, name string) (string, error) {
+	value := mux.Vars(r)[name]
+	if value == "" {
+		return "", errNotFound
+	}
+	return value, nil
+}
+
+// Проект из пути запроса: /project/{projectname}.
+// minRole - минимальная роль в проекте, необходимая для действия.
+func (a *API) requestProject(r *http.Request, minRole string) (*model.Project, error) {
+	projectSlug, err := pathVar(r, "projectname")
+	if err != nil {
+		return nil, err
+	}
+	project, err := a.resolveProject(currentUser(r), projectSlug, false, minRole)
+	return project, redirectMoved(r, "projectname", err)
+}
+
+// Шаблон из пути запроса: /template/{templatename}
+func (a *API) requestTemplate(r *http.Request, minRole string) (*model.Project, error) {
+	templateSlug, err := pathVar(r, "templatename")
+	if err != nil {
+		return nil, err
+	}
+	template, err := a.resolveProject(currentUser(r), templateSlug, true, minRole)
+	return template, redirectMoved(r, "templatename", err)
+}
+
+// Раздел из пути запроса: /project/{projectname}/section/{sectionname}
+func (a *API) requestSection(r *http.Request, minRole string) (*model.Project, *model.Section, error) {
+	project, err := a.requestProject(r, minRole)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sectionSlug, err := pathVar(r, "sectionname")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	section, err := a.resolveSection(project, sectionSlug)
+	if err != nil {
+		return nil, nil, redirectMoved(r, "sectionname", err)
+	}
+	return project, section, nil
+}
+
+// Замена slugMovedError перенаправлением на текущий адрес
+func redirectMoved(r *http.Request, name string, err error) error {
+	var moved *slugMovedError
+	if errors.As(err, &moved) {
+		return movedRequest(r, name, moved.slug)
+	}
+	return err
+}
+
+// Содержимое из пути запроса: /project/{projectname}/section/{sectionname}/content/{contentid}
+func (a *API) requestContent(r *http.Request, minRole string) (*model.Section, *model.Content, error) {
+	_, section, err := a.requestSection(r, minRole)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	contentID, err := pathVar(r, "contentid")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	content, err := a.resolveContent(section, contentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return section, content, nil
+}
+
+// Ответ на ошибку разрешения объекта
+func writeResolveError(w http.ResponseWriter, err error, notFound string) {
+	var moved *movedError
+	if errors.As(err, &moved) {
+		w.Header().Set("Location", moved.location)
+		http.Error(w, "Moved to "+moved.location, moved.status)
+		return
+	}
+	if errors.Is(err, errNotFound) {
+		http.Error(w, notFound, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errForbidden) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	http.Error(w, "Database error", http.StatusInternalServerError)
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+)
+
+// Проект пользователя с одним разделом и одним содержимым
+func createTestProject(t *testing.T, a *API, owner *model.User, name string) (*model.Project, *model.Section, *model.Content) {
+	t.Helper()
+
+	project := &model.Project{UserID: owner.ID, Name: name, Slug: name, Visibility: model.VisibilityPrivate}
+	if err := a.DB.Create(project).Error; err != nil {
+		t.Fatal(err)
+	}
+	section := &model.Section{ProjectID: project.ID, Title: "Intro", Slug: "intro"}
+	if err := a.DB.Create(section).Error; err != nil {
+		t.Fatal(err)
+	}
+	content := &model.Content{SectionID: section.ID, Type: "text", Data: "secret"}
+	if err := a.DB.Create(content).Error; err != nil {
+		t.Fatal(err)
+	}
+	return project, section, content
+}
+
+func TestResolveProjectOfOtherUserPostgres(t *testing.T) {
+	a := newTestAPI(t)
+	alice := createTestUser(t, a, "alice")
+	bob := createTestUser(t, a, "bob")
+	createTestProject(t, a, alice, "alice-project")
+
+	_, err := a.resolveProject(bob, "alice-project", false, model.ProjectRoleViewer)
+	if !errors.Is(err, errNotFound) {
+		t.Fatalf("resolveProject for another user: got %v, want errNotFound", err)
+	}
+
+	// Владелец видит свой проект
+	if _, err := a.resolveProject(alice, "alice-project", false, model.ProjectRoleOwner); err != nil {
+		t.Fatalf("resolveProject for owner: %v", err)
+	}
+}
+
+func TestResolveSectionAndContentOfOtherUserPostgres(t *testing.T) {
+	a := newTestAPI(t)
+	alice := createTestUser(t, a, "alice")
+	bob := createTestUser(t, a, "bob")
+	_, aliceSection, aliceContent := createTestProject(t, a, alice, "alice-project")
+	bobProject, bobSection, _ := createTestProject(t, a, bob, "bob-project")
+
+	// Раздел другого пользователя не находится через свой проект
+	aliceSection.Slug = "alice-only"
+	if err := a.DB.Save(aliceSection).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.resolveSection(bobProject, "alice-only"); !errors.Is(err, errNotFound) {
+		t.Fatalf("resolveSection for another user's section: got %v, want errNotFound", err)
+	}
+
+	// Содержимое другого пользователя не находится через свой раздел
+	_, err := a.resolveContent(bobSection, strconv.FormatUint(uint64(aliceContent.ID), 10))
+	if !errors.Is(err, errNotFound) {
+		t.Fatalf("resolveContent for another user's content: got %v, want errNotFound", err)
+	}
+}
+
+// Идентификаторы разделов и содержимого в теле создания проекта
+// не перепривязывают чужие объекты к новому проекту
+func TestCreateProjectIgnoresNestedIDsPostgres(t *testing.T) {
+	a := newTestAPI(t)
+	alice := createTestUser(t, a, "alice")
+	bob := createTestUser(t, a, "bob")
+	aliceProject, aliceSection, aliceContent := createTestProject(t, a, alice, "alice-project")
+
+	body := `{"Name": "Stolen", "sections": [{"ID": ` + strconv.FormatUint(uint64(aliceSection.ID), 10) +
+		`, "Title": "Taken", "Contents": [{"ID": ` + strconv.FormatUint(uint64(aliceContent.ID), 10) +
+		`, "Type": "text", "Data": "overwritten"}]}]}`
+	r := withUser(httptest.NewRequest(http.MethodPost, "/projects/create", strings.NewReader(body)), bob)
+	w := httptest.NewRecorder()
+	a.createProjectHandler(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create project: status %d: %s", w.Code, w.Body.String())
+	}
+
+	var section model.Section
+	if err := a.DB.First(&section, aliceSection.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if section.ProjectID != aliceProject.ID || section.Title != aliceSection.Title {
+		t.Fatalf("section was taken over: project %d, title %q", section.ProjectID, section.Title)
+	}
+
+	var content model.Content
+	if err := a.DB.First(&content, aliceContent.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if content.SectionID != aliceSection.ID || content.Data != aliceContent.Data {
+		t.Fatalf("content was taken over: section %d, data %q", content.SectionID, content.Data)
+	}
+
+	// Новый проект получил собственные копии
+	var copies int64
+	err := a.DB.Model(&model.Section{}).Joins("JOIN projects ON projects.id = sections.project_id").
+		Where("projects.user_id = ? AND sections.title = ?", bob.ID, "Taken").Count(&copies).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copies != 1 {
+		t.Fatalf("new project has %d sections titled Taken, want 1", copies)
+	}
+}
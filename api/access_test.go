@@ -0,0 +1,196 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+)
+
+// Пользователи и объекты для проверок доступа: у alice и bob по проекту
+// с разделом "intro" и одним содержимым
+type accessFixture struct {
+	api          *API
+	db           *fakeDB
+	alice, bob   *model.User
+	aliceProject *model.Project
+	bobProject   *model.Project
+	aliceSection *model.Section
+	bobSection   *model.Section
+	aliceContent *model.Content
+}
+
+func newAccessFixture(t *testing.T) *accessFixture {
+	t.Helper()
+
+	a, db := newFakeAPI(t)
+	f := &accessFixture{api: a, db: db}
+	f.alice = &model.User{Username: "alice"}
+	f.alice.ID = 1
+	f.bob = &model.User{Username: "bob"}
+	f.bob.ID = 2
+
+	f.aliceProject, f.aliceSection, f.aliceContent = f.addProject(f.alice, 10, "alice-project")
+	f.bobProject, f.bobSection, _ = f.addProject(f.bob, 20, "bob-project")
+	return f
+}
+
+// Личный проект пользователя; идентификаторы раздела и содержимого
+// следуют за идентификатором проекта
+func (f *accessFixture) addProject(owner *model.User, id uint, slug string) (*model.Project, *model.Section, *model.Content) {
+	project := &model.Project{UserID: owner.ID, Name: slug, Slug: slug, Visibility: model.VisibilityPrivate}
+	project.ID = id
+	section := &model.Section{ProjectID: id, Title: "Intro", Slug: "intro"}
+	section.ID = id + 1
+	content := &model.Content{SectionID: section.ID, Type: "text", Data: "secret of " + owner.Username}
+	content.ID = id + 2
+
+	f.db.insert("projects", fakeRow{
+		"id": int64(id), "user_id": int64(owner.ID), "organization_id": nil, "name": slug, "slug": slug,
+		"is_template": false, "visibility": model.VisibilityPrivate, "deleted_at": nil,
+	})
+	f.db.insert("sections", fakeRow{
+		"id": int64(section.ID), "project_id": int64(id), "title": section.Title, "slug": section.Slug, "deleted_at": nil,
+	})
+	f.db.insert("contents", fakeRow{
+		"id": int64(content.ID), "section_id": int64(section.ID), "type": content.Type, "data": content.Data, "deleted_at": nil,
+	})
+	return project, section, content
+}
+
+// GET-запрос от имени пользователя с переменными пути
+func (f *accessFixture) get(user *model.User, handler http.HandlerFunc, vars map[string]string) *httptest.ResponseRecorder {
+	r := withUser(httptest.NewRequest(http.MethodGet, "/", nil), user)
+	w := httptest.NewRecorder()
+	handler(w, mux.SetURLVars(r, vars))
+	return w
+}
+
+func TestResolveProjectOfOtherUser(t *testing.T) {
+	f := newAccessFixture(t)
+
+	_, err := f.api.resolveProject(f.bob, f.aliceProject.Slug, false, model.ProjectRoleViewer)
+	if !errors.Is(err, errNotFound) {
+		t.Fatalf("resolveProject for another user: got %v, want errNotFound", err)
+	}
+
+	// Владелец находит тот же проект
+	project, err := f.api.resolveProject(f.alice, f.aliceProject.Slug, false, model.ProjectRoleOwner)
+	if err != nil {
+		t.Fatalf("resolveProject for owner: %v", err)
+	}
+	if project.ID != f.aliceProject.ID {
+		t.Fatalf("resolveProject for owner: project %d, want %d", project.ID, f.aliceProject.ID)
+	}
+}
+
+func TestResolveSectionOfOtherUser(t *testing.T) {
+	f := newAccessFixture(t)
+
+	// Раздел alice с тем же адресом не находится через проект bob
+	section, err := f.api.resolveSection(f.bobProject, f.aliceSection.Slug)
+	if err != nil {
+		t.Fatalf("resolveSection in own project: %v", err)
+	}
+	if section.ID != f.bobSection.ID {
+		t.Fatalf("resolveSection in own project: section %d, want %d", section.ID, f.bobSection.ID)
+	}
+
+	if _, err := f.api.resolveSection(f.bobProject, "alice-only"); !errors.Is(err, errNotFound) {
+		t.Fatalf("resolveSection for a missing section: got %v, want errNotFound", err)
+	}
+}
+
+func TestResolveContentOfOtherUser(t *testing.T) {
+	f := newAccessFixture(t)
+
+	_, err := f.api.resolveContent(f.bobSection, fmt.Sprint(f.aliceContent.ID))
+	if !errors.Is(err, errNotFound) {
+		t.Fatalf("resolveContent for another user's content: got %v, want errNotFound", err)
+	}
+
+	content, err := f.api.resolveContent(f.aliceSection, fmt.Sprint(f.aliceContent.ID))
+	if err != nil {
+		t.Fatalf("resolveContent for owner: %v", err)
+	}
+	if content.ID != f.aliceContent.ID {
+		t.Fatalf("resolveContent for owner: content %d, want %d", content.ID, f.aliceContent.ID)
+	}
+}
+
+func TestOtherUserObjectsNotFound(t *testing.T) {
+	f := newAccessFixture(t)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		vars    map[string]string
+	}{
+		{"project", f.api.getProjectHandler, map[string]string{"projectname": f.aliceProject.Slug}},
+		{"section", f.api.getSectionHandler, map[string]string{"projectname": f.aliceProject.Slug, "sectionname": f.aliceSection.Slug}},
+		{"content through own section", f.api.getContentHandler, map[string]string{
+			"projectname": f.bobProject.Slug,
+			"sectionname": f.bobSection.Slug,
+			"contentid":   fmt.Sprint(f.aliceContent.ID),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := f.get(f.bob, tt.handler, tt.vars)
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("status %d, want 404: %s", w.Code, w.Body.String())
+			}
+			if strings.Contains(w.Body.String(), "secret of alice") {
+				t.Fatalf("response leaks alice's content: %s", w.Body.String())
+			}
+		})
+	}
+}
+
+// Идентификаторы разделов и содержимого в теле создания проекта
+// не попадают в запросы к базе: чужие объекты не перепривязываются
+func TestCreateProjectIgnoresNestedIDs(t *testing.T) {
+	f := newAccessFixture(t)
+
+	body := fmt.Sprintf(`{"Name": "Stolen", "sections": [{"ID": %d, "ProjectID": %d, "Title": "Taken",
+		"Contents": [{"ID": %d, "SectionID": %d, "Type": "text", "Data": "overwritten"}]}]}`,
+		f.aliceSection.ID, f.aliceProject.ID, f.aliceContent.ID, f.aliceSection.ID)
+	r := withUser(httptest.NewRequest(http.MethodPost, "/projects/create", strings.NewReader(body)), f.bob)
+	w := httptest.NewRecorder()
+	f.api.createProjectHandler(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create project: status %d: %s", w.Code, w.Body.String())
+	}
+
+	foreign := map[string]bool{
+		fmt.Sprint(f.aliceProject.ID): true,
+		fmt.Sprint(f.aliceSection.ID): true,
+		fmt.Sprint(f.aliceContent.ID): true,
+	}
+	inserts := 0
+	for _, query := range f.db.log() {
+		if !strings.HasPrefix(query.SQL, "INSERT INTO \"sections\"") && !strings.HasPrefix(query.SQL, "INSERT INTO \"contents\"") &&
+			!strings.HasPrefix(query.SQL, "UPDATE") {
+			continue
+		}
+		inserts++
+		// Без идентификатора ON CONFLICT ("id") не может задеть существующие строки
+		if columns := strings.SplitN(query.SQL, " VALUES ", 2)[0]; strings.Contains(columns, `"id"`) {
+			t.Errorf("query writes explicit ids: %s", query.SQL)
+		}
+		for _, arg := range query.Args {
+			if foreign[fmt.Sprint(arg)] {
+				t.Errorf("query uses id %v from the request: %s %v", arg, query.SQL, query.Args)
+			}
+		}
+	}
+	if inserts != 2 {
+		t.Fatalf("%d section and content writes, want 2", inserts)
+	}
+}
@@ -0,0 +1,375 @@
+package api
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+	"github.com/roGal1k/golang-beginner/internal/mail"
+)
+
+// Запрос на удаление учётной записи. Данные удаляются окончательно после
+// срока ожидания; до этого удаление можно отменить, войдя в учётную запись.
+func (a *API) deleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		Password string `json:"password"`
+		Confirm  string `json:"confirm"` // Имя пользователя, если пароль не задан
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user := currentUser(r)
+
+	if user.Password != "" {
+		userKey := userThrottleKey(user.Username)
+		retry, err := a.throttleRetryAfter(userKey)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if retry > 0 {
+			writeTooManyAttempts(w, retry)
+			return
+		}
+
+		err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.Password))
+		if err != nil {
+			if !a.recordAttempt(w, userKey, a.Config.Auth.Throttle.UserFreeAttempts) {
+				return
+			}
+			http.Error(w, "Invalid password", http.StatusForbidden)
+			return
+		}
+	} else if request.Confirm != user.Username {
+		http.Error(w, "Confirm deletion with your username", http.StatusBadRequest)
+		return
+	}
+
+	// Организация не должна остаться без владельца
+	ownedOrgs, err := a.soleOwnedOrganizations(user.ID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if ownedOrgs > 0 {
+		http.Error(w, "Transfer ownership of your organizations before deleting the account", http.StatusConflict)
+		return
+	}
+
+	scheduledAt := time.Now().Add(time.Duration(a.Config.Auth.AccountDeletionGrace))
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(user).Update("deletion_scheduled_at", scheduledAt).Error
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		err = tx.Model(&model.Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			Update("revoked_at", now).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&model.PersonalAccessToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			Update("revoked_at", now).Error
+	})
+	if err != nil {
+		http.Error(w, "Failed to schedule deletion", http.StatusInternalServerError)
+		return
+	}
+
+	if user.Email != "" {
+		a.sendMail(mail.Message{
+			To:      user.Email,
+			Subject: "Account deletion scheduled",
+			Body: fmt.Sprintf("Hello, %s!\n\nYour account and all of its data will be deleted on %s.\n"+
+				"To keep your account, sign in and cancel the deletion before then.\n",
+				user.Username, scheduledAt.UTC().Format(time.RFC1123)),
+		})
+	}
+
+	a.audit(r, auditEntry{
+		Action:     auditAccountDeletionSchedule,
+		TargetType: auditTargetUser,
+		TargetID:   user.ID,
+		Metadata:   map[string]interface{}{"deletion_scheduled_at": scheduledAt},
+	})
+
+	w.WriteHeader(http.StatusAccepted)
+	response := map[string]interface{}{
+		"message":               "Account deletion scheduled",
+		"deletion_scheduled_at": scheduledAt,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Отмена запланированного удаления
+func (a *API) cancelAccountDeletionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user := currentUser(r)
+	if user.DeletionScheduledAt == nil {
+		http.Error(w, "Account deletion is not scheduled", http.StatusNotFound)
+		return
+	}
+
+	err := a.DB.Model(user).Update("deletion_scheduled_at", nil).Error
+	if err != nil {
+		http.Error(w, "Failed to cancel deletion", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditAccountDeletionCancel, TargetType: auditTargetUser, TargetID: user.ID})
+
+	response := map[string]string{
+		"message": "Account deletion cancelled",
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Архив со всеми данными пользователя: JSON-файлы и загруженные файлы в media/
+func (a *API) exportAccountHandler(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+
+	var projects []model.Project
+	err := a.DB.Where("user_id = ? AND organization_id IS NULL", user.ID).Preload("Sections.Contents").Find(&projects).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch projects", http.StatusInternalServerError)
+		return
+	}
+	ids := make([]uint, 0, len(projects))
+	for _, project := range projects {
+		ids = append(ids, project.ID)
+	}
+	tags, err := a.projectTags(ids)
+	if err != nil {
+		http.Error(w, "Failed to fetch projects", http.StatusInternalServerError)
+		return
+	}
+	for i := range projects {
+		projects[i].Tags = tags[projects[i].ID]
+	}
+
+	var files []model.Media
+	err = a.DB.Where("user_id = ?", user.ID).Order("id").Find(&files).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch files", http.StatusInternalServerError)
+		return
+	}
+
+	var identities []model.UserIdentity
+	err = a.DB.Where("user_id = ?", user.ID).Find(&identities).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch identities", http.StatusInternalServerError)
+		return
+	}
+
+	var tokens []model.PersonalAccessToken
+	err = a.DB.Where("user_id = ?", user.ID).Find(&tokens).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch tokens", http.StatusInternalServerError)
+		return
+	}
+
+	var sessions []model.Session
+	err = a.DB.Where("user_id = ?", user.ID).Find(&sessions).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch sessions", http.StatusInternalServerError)
+		return
+	}
+
+	// Секреты (хеши паролей и токенов, секрет TOTP) в архив не попадают
+	type exportedMedia struct {
+		mediaView
+		Path string `json:"path"`
+	}
+	type exportedIdentity struct {
+		Provider  string    `json:"provider"`
+		Subject   string    `json:"subject"`
+		Email     string    `json:"email"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	type exportedSession struct {
+		UserAgent  string     `json:"user_agent"`
+		IP         string     `json:"ip"`
+		CreatedAt  time.Time  `json:"created_at"`
+		LastSeenAt time.Time  `json:"last_seen_at"`
+		RevokedAt  *time.Time `json:"revoked_at"`
+	}
+
+	mediaEntries := make([]exportedMedia, 0, len(files))
+	for i := range files {
+		mediaEntries = append(mediaEntries, exportedMedia{
+			mediaView: a.newMediaView(&files[i]),
+			Path:      "media/" + path.Base(files[i].Key),
+		})
+	}
+	identityEntries := make([]exportedIdentity, 0, len(identities))
+	for _, identity := range identities {
+		identityEntries = append(identityEntries, exportedIdentity{identity.Provider, identity.Subject, identity.Email, identity.CreatedAt})
+	}
+	tokenEntries := make([]personalTokenView, 0, len(tokens))
+	for i := range tokens {
+		tokenEntries = append(tokenEntries, newPersonalTokenView(&tokens[i]))
+	}
+	sessionEntries := make([]exportedSession, 0, len(sessions))
+	for _, session := range sessions {
+		sessionEntries = append(sessionEntries, exportedSession{session.UserAgent, session.IP, session.CreatedAt, session.LastSeenAt, session.RevokedAt})
+	}
+
+	a.audit(r, auditEntry{Action: auditAccountExported, TargetType: auditTargetUser, TargetID: user.ID})
+
+	filename := fmt.Sprintf("%s-export-%s.zip", user.Username, time.Now().UTC().Format("20060102"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	// Архив пишется сразу в ответ; после начала записи ошибку можно только залогировать
+	archive := zip.NewWriter(w)
+	err = a.writeExport(r.Context(), archive, map[string]interface{}{
+		"account.json":    newProfile(user),
+		"projects.json":   projects,
+		"media.json":      mediaEntries,
+		"identities.json": identityEntries,
+		"tokens.json":     tokenEntries,
+		"sessions.json":   sessionEntries,
+	}, files)
+	if err == nil {
+		err = archive.Close()
+	}
+	if err != nil {
+		log.Printf("account export for user %d: %v", user.ID, err)
+	}
+}
+
+// Запись JSON-файлов и файлов из хранилища в архив
+func (a *API) writeExport(ctx context.Context, archive *zip.Writer, documents map[string]interface{}, files []model.Media) error {
+	for name, document := range documents {
+		entry, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(entry)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(document); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range files {
+		body, err := a.Storage.Open(ctx, file.Key)
+		if err != nil {
+			return fmt.Errorf("media %s: %w", file.Key, err)
+		}
+		entry, err := archive.Create("media/" + path.Base(file.Key))
+		if err == nil {
+			_, err = io.Copy(entry, body)
+		}
+		body.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Количество организаций, в которых пользователь - единственный владелец
+func (a *API) soleOwnedOrganizations(userID uint) (int64, error) {
+	var count int64
+	err := a.DB.Model(&model.OrganizationMember{}).
+		Where("user_id = ? AND role = ? AND NOT EXISTS (?)", userID, model.OrgRoleOwner,
+			a.DB.Table("organization_members AS owners").Select("1").
+				Where("owners.organization_id = organization_members.organization_id AND owners.user_id <> ? AND owners.role = ? AND owners.deleted_at IS NULL",
+					userID, model.OrgRoleOwner)).
+		Count(&count).Error
+	return count, err
+}
+
+// Окончательное удаление учётных записей, срок ожидания которых истёк
+func (a *API) purgeDeletedAccounts() error {
+	var users []model.User
+	err := a.DB.Unscoped().Where("deletion_scheduled_at < ?", time.Now()).Find(&users).Error
+	if err != nil {
+		return err
+	}
+
+	for i := range users {
+		if err := a.purgeAccount(&users[i]); err != nil {
+			return fmt.Errorf("user %d: %w", users[i].ID, err)
+		}
+	}
+	return nil
+}
+
+// Удаление пользователя со всеми данными. Сначала удаляются объекты
+// в хранилище: при ошибке записи в базе остаются и удаление повторяется позже.
+func (a *API) purgeAccount(user *model.User) error {
+	var files []model.Media
+	err := a.DB.Unscoped().Where("user_id = ?", user.ID).Find(&files).Error
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	for _, file := range files {
+		if err := a.Storage.Delete(ctx, file.Key); err != nil {
+			return err
+		}
+	}
+
+	return a.DB.Transaction(func(tx *gorm.DB) error {
+		// Session позволяет строить подзапросы и запросы от одного tx
+		tx = tx.Unscoped().Session(&gorm.Session{})
+
+		// Проекты организаций остаются у организаций
+		projects := tx.Model(&model.Project{}).Select("id").Where("user_id = ? AND organization_id IS NULL", user.ID)
+		if err := purgeProjectsTx(tx, projects); err != nil {
+			return err
+		}
+
+		sessions := tx.Model(&model.Session{}).Select("id").Where("user_id = ?", user.ID)
+
+		steps := []struct {
+			model interface{}
+			where string
+			arg   interface{}
+		}{
+			{&model.ProjectMember{}, "user_id = ?", user.ID},
+			{&model.ProjectInvitation{}, "invitee_id = ?", user.ID},
+			{&model.OrganizationMember{}, "user_id = ?", user.ID},
+			{&model.Media{}, "user_id = ?", user.ID},
+			{&model.RefreshToken{}, "session_id IN (?)", sessions},
+			{&model.Session{}, "user_id = ?", user.ID},
+			{&model.RevokedToken{}, "user_id = ?", user.ID},
+			{&model.UserToken{}, "user_id = ?", user.ID},
+			{&model.RecoveryCode{}, "user_id = ?", user.ID},
+			{&model.LoginChallenge{}, "user_id = ?", user.ID},
+			{&model.PersonalAccessToken{}, "user_id = ?", user.ID},
+			{&model.UserIdentity{}, "user_id = ?", user.ID},
+			{&model.OIDCLoginState{}, "link_user_id = ?", user.ID},
+			{&model.LoginThrottle{}, "key = ?", userThrottleKey(user.Username)},
+		}
+		for _, step := range steps {
+			if err := tx.Where(step.where, step.arg).Delete(step.model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(user).Error
+	})
+}
@@ -0,0 +1,409 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+)
+
+var (
+	errAccountDisabled = errors.New("account is disabled")
+	errLastAdmin       = errors.New("at least one active admin is required")
+)
+
+// Размер страницы списка пользователей
+const (
+	defaultUsersLimit = 50
+	maxUsersLimit     = 200
+)
+
+// Проверка, что пользователю можно выдать сессию
+func loginAllowed(w http.ResponseWriter, user *model.User) bool {
+	if user.DisabledAt != nil {
+		http.Error(w, "Account is disabled", http.StatusForbidden)
+		return false
+	}
+	if user.PasswordResetRequired {
+		http.Error(w, "Password reset required", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// Описание пользователя для администратора
+type adminUserView struct {
+	ID                    uint       `json:"id"`
+	Username              string     `json:"username"`
+	Email                 string     `json:"email"`
+	Role                  string     `json:"role"`
+	EmailVerifiedAt       *time.Time `json:"email_verified_at"`
+	TwoFactor             bool       `json:"two_factor_enabled"`
+	DisabledAt            *time.Time `json:"disabled_at"`
+	PasswordResetRequired bool       `json:"password_reset_required"`
+	CreatedAt             time.Time  `json:"created_at"`
+}
+
+func newAdminUserView(user *model.User) adminUserView {
+	return adminUserView{
+		ID:                    user.ID,
+		Username:              user.Username,
+		Email:                 user.Email,
+		Role:                  user.Role,
+		EmailVerifiedAt:       user.EmailVerifiedAt,
+		TwoFactor:             user.TOTPEnabledAt != nil,
+		DisabledAt:            user.DisabledAt,
+		PasswordResetRequired: user.PasswordResetRequired,
+		CreatedAt:             user.CreatedAt,
+	}
+}
+
+// Пользователь из пути запроса
+func (a *API) requestUser(w http.ResponseWriter, r *http.Request) (*model.User, bool) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	var user model.User
+	err = a.DB.Where("id = ?", id).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return &user, true
+}
+
+// Список пользователей с поиском по имени и email.
+// Параметры: q, role, status (active, disabled), offset, limit.
+func (a *API) getUsersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.URL.Query()
+	query := a.DB.Model(&model.User{})
+	if q := params.Get("q"); q != "" {
+		pattern := "%" + q + "%"
+		query = query.Where("username ILIKE ? OR email ILIKE ?", pattern, pattern)
+	}
+	if role := params.Get("role"); role != "" {
+		query = query.Where("role = ?", role)
+	}
+	switch params.Get("status") {
+	case "":
+	case "active":
+		query = query.Where("disabled_at IS NULL")
+	case "disabled":
+		query = query.Where("disabled_at IS NOT NULL")
+	default:
+		http.Error(w, "Unknown status", http.StatusBadRequest)
+		return
+	}
+
+	limit, err := queryInt(params.Get("limit"), defaultUsersLimit)
+	if err != nil || limit <= 0 || limit > maxUsersLimit {
+		http.Error(w, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+	offset, err := queryInt(params.Get("offset"), 0)
+	if err != nil || offset < 0 {
+		http.Error(w, "Invalid offset", http.StatusBadRequest)
+		return
+	}
+
+	var total int64
+	err = query.Count(&total).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch users", http.StatusInternalServerError)
+		return
+	}
+
+	var users []model.User
+	err = query.Order("id").Offset(offset).Limit(limit).Find(&users).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch users", http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]adminUserView, 0, len(users))
+	for i := range users {
+		views = append(views, newAdminUserView(&users[i]))
+	}
+	response := map[string]interface{}{
+		"users": views,
+		"total": total,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Целое число из параметра запроса
+func queryInt(value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func (a *API) getUserHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user, ok := a.requestUser(w, r)
+	if !ok {
+		return
+	}
+	json.NewEncoder(w).Encode(newAdminUserView(user))
+}
+
+// Проверка, что после изменения останется хотя бы один активный администратор
+func (a *API) ensureOtherAdmin(tx *gorm.DB, user *model.User) error {
+	if user.Role != model.RoleAdmin || user.DisabledAt != nil {
+		return nil
+	}
+
+	var admins int64
+	err := tx.Model(&model.User{}).
+		Where("role = ? AND disabled_at IS NULL AND id <> ?", model.RoleAdmin, user.ID).
+		Count(&admins).Error
+	if err != nil {
+		return err
+	}
+	if admins == 0 {
+		return errLastAdmin
+	}
+	return nil
+}
+
+// Назначение роли
+func (a *API) setUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		Role string `json:"role"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.Role != model.RoleUser && request.Role != model.RoleAdmin {
+		http.Error(w, "Unknown role", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := a.requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	previousRole := user.Role
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		if request.Role != model.RoleAdmin {
+			if err := a.ensureOtherAdmin(tx, user); err != nil {
+				return err
+			}
+		}
+		return tx.Model(user).Update("role", request.Role).Error
+	})
+	if errors.Is(err, errLastAdmin) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update role", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{
+		Action:     auditAdminUserRole,
+		TargetType: auditTargetUser,
+		TargetID:   user.ID,
+		Before:     map[string]string{"Role": previousRole},
+		After:      map[string]string{"Role": request.Role},
+	})
+
+	json.NewEncoder(w).Encode(newAdminUserView(user))
+}
+
+// Блокировка учётной записи: вход запрещается, все сессии и токены перестают действовать
+func (a *API) disableUserHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user, ok := a.requestUser(w, r)
+	if !ok {
+		return
+	}
+	if user.ID == currentUser(r).ID {
+		http.Error(w, "You cannot disable your own account", http.StatusConflict)
+		return
+	}
+	if user.DisabledAt != nil {
+		json.NewEncoder(w).Encode(newAdminUserView(user))
+		return
+	}
+
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := a.ensureOtherAdmin(tx, user); err != nil {
+			return err
+		}
+		if err := tx.Model(user).Update("disabled_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			Update("revoked_at", time.Now()).Error
+	})
+	if errors.Is(err, errLastAdmin) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to disable user", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditAdminUserDisabled, TargetType: auditTargetUser, TargetID: user.ID})
+
+	json.NewEncoder(w).Encode(newAdminUserView(user))
+}
+
+// Снятие блокировки
+func (a *API) enableUserHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user, ok := a.requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := a.DB.Model(user).Update("disabled_at", nil).Error
+	if err != nil {
+		http.Error(w, "Failed to enable user", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditAdminUserEnabled, TargetType: auditTargetUser, TargetID: user.ID})
+
+	json.NewEncoder(w).Encode(newAdminUserView(user))
+}
+
+// Окончательное удаление учётной записи со всеми проектами, файлами,
+// сессиями и персональными токенами
+func (a *API) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user, ok := a.requestUser(w, r)
+	if !ok {
+		return
+	}
+	if user.ID == currentUser(r).ID {
+		http.Error(w, "You cannot delete your own account", http.StatusConflict)
+		return
+	}
+
+	err := a.ensureOtherAdmin(a.DB, user)
+	if errors.Is(err, errLastAdmin) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// Организация не должна остаться без владельца
+	ownedOrgs, err := a.soleOwnedOrganizations(user.ID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if ownedOrgs > 0 {
+		http.Error(w, "Transfer ownership of the user's organizations before deleting the account", http.StatusConflict)
+		return
+	}
+
+	// Пользователь удаляется со всеми данными, как по истечении
+	// срока ожидания удаления учётной записи
+	if err := a.purgeAccount(user); err != nil {
+		log.Printf("delete user %d: %v", user.ID, err)
+		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{
+		Action:     auditAdminUserDeleted,
+		TargetType: auditTargetUser,
+		TargetID:   user.ID,
+		Metadata:   map[string]interface{}{"username": user.Username},
+	})
+
+	response := map[string]string{
+		"message": "User deleted",
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Принудительная смена пароля: сессии завершаются, вход возможен
+// только после восстановления пароля по ссылке из письма
+func (a *API) forcePasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user, ok := a.requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(user).Updates(map[string]interface{}{
+			"password_reset_required": true,
+			"token_version":           gorm.Expr("token_version + 1"),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		// Сессии и персональные токены перестают действовать до смены пароля
+		now := time.Now()
+		err = tx.Model(&model.Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			Update("revoked_at", now).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&model.PersonalAccessToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			Update("revoked_at", now).Error
+	})
+	if err != nil {
+		http.Error(w, "Failed to require password reset", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditAdminPasswordReset, TargetType: auditTargetUser, TargetID: user.ID})
+
+	emailSent := false
+	if user.Email != "" {
+		err = a.sendPasswordReset(user, "An administrator has required you to set a new password.")
+		if err != nil {
+			http.Error(w, "Failed to create reset token", http.StatusInternalServerError)
+			return
+		}
+		emailSent = true
+	}
+
+	response := map[string]interface{}{
+		"message":    "Password reset required",
+		"email_sent": emailSent,
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -2,53 +2,41 @@ package api
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
-	"time"
+	netmail "net/mail"
+	"sync"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gorilla/mux"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 
 	"github.com/roGal1k/golang-beginner/assets/model"
+	"github.com/roGal1k/golang-beginner/internal/config"
+	"github.com/roGal1k/golang-beginner/internal/mail"
+	"github.com/roGal1k/golang-beginner/internal/oidc"
+	"github.com/roGal1k/golang-beginner/internal/passwords"
+	"github.com/roGal1k/golang-beginner/internal/storage"
 )
 
 type API struct {
-	DB *gorm.DB
-}
-
-type Claims struct {
-	Username string `json:"username"`
-	jwt.StandardClaims
-}
-
-// Функция для верификации токена и получения информации о пользователе
-func (a *API) authorization(r *http.Request) (*Claims, error) {
-	// Извлекаем токен из запроса
-	tokenString := r.Header.Get("Authorization")
-	if tokenString == "" {
-		return nil, fmt.Errorf("authorization token required")
-	}
-
-	// Верификация токена
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte("your-secret-key"), nil // Замените "your-secret-key" на ваш секретный ключ
-	})
-	if err != nil {
-		return nil, fmt.Errorf("invalid token")
-	}
+	DB     *gorm.DB
+	Config *config.Config
+	Keys   *KeySet
+	Mailer mail.Mailer
 
-	// Проверка валидности токена и извлечение информации о пользователе
-	claims, ok := token.Claims.(*Claims)
-	if !ok || !token.Valid {
-		return nil, fmt.Errorf("invalid token")
-	}
+	PasswordPolicy *passwords.Policy
+	Storage        storage.Storage
 
-	return claims, nil
+	// Провайдеры OpenID Connect после discovery
+	oidcMu        sync.Mutex
+	oidcProviders map[string]*oidc.Provider
 }
 
+// Ответ на регистрацию с занятым именем пользователя или email
+const registrationConflictMessage = "Username or email is already in use"
+
 func (a *API) registerHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -65,6 +53,53 @@ func (a *API) registerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Ограничение числа регистраций с одного адреса
+	registerKey := registerThrottleKey(a.clientIP(r))
+	retry, err := a.throttleRetryAfter(registerKey)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if retry > 0 {
+		writeTooManyAttempts(w, retry)
+		return
+	}
+	if !a.recordAttempt(w, registerKey, a.Config.Auth.Throttle.RegisterFreeAttempts) {
+		return
+	}
+
+	// Проверка пароля по политике
+	err = a.PasswordPolicy.Check(user.Password, user.Username)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Email необязателен, но нужен для восстановления доступа
+	if user.Email != "" {
+		address, err := netmail.ParseAddress(user.Email)
+		if err != nil || address.Address != user.Email {
+			http.Error(w, "Invalid email", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Занятые имя и email дают одинаковый ответ, чтобы по регистрации
+	// нельзя было узнать, есть ли учётная запись с данным адресом
+	taken := a.DB.Unscoped().Model(&model.User{}).Where("username = ?", user.Username)
+	if user.Email != "" {
+		taken = taken.Or("email = ? AND deleted_at IS NULL", user.Email)
+	}
+	var count int64
+	if err := taken.Count(&count).Error; err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if count > 0 {
+		http.Error(w, registrationConflictMessage, http.StatusConflict)
+		return
+	}
+
 	// Хеширование пароля
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -72,34 +107,60 @@ func (a *API) registerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	expirationTime := time.Now().Add(31 * 24 * time.Hour) // Срок действия токена - 31 день
-	claims := &Claims{
+	newUser := model.User{
 		Username: user.Username,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
-		},
+		Password: string(hashedPassword),
+		Email:    user.Email,
 	}
-	fmt.Print(claims.Id)
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte("your-secret-key")) // Замените "your-secret-key" на ваш секретный ключ
+	// Сохранение пользователя в базе данных
+	err = a.DB.Create(&newUser).Error
+	if a.isUniqueViolation(err) {
+		// Одновременная регистрация с тем же именем
+		http.Error(w, registrationConflictMessage, http.StatusConflict)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		http.Error(w, "Failed to save user", http.StatusInternalServerError)
 		return
 	}
 
-	// Сохранение пользователя в базе данных
-	err = a.DB.Create(&model.User{
-		Username: user.Username,
-		Password: string(hashedPassword),
-		Token:    signedToken,
-	}).Error
+	// Письмо для подтверждения email
+	if newUser.Email != "" {
+		err = a.sendEmailVerification(&newUser)
+		if err != nil {
+			http.Error(w, "Failed to create verification token", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	a.audit(r, auditEntry{
+		ActorID:    &newUser.ID,
+		Action:     auditRegister,
+		TargetType: auditTargetUser,
+		TargetID:   newUser.ID,
+		After:      newUser,
+	})
+
+	tokens, err := a.createSession(&newUser, a.requestDevice(r))
 	if err != nil {
-		http.Error(w, "Failed to save user", http.StatusInternalServerError)
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// Нарушение уникального индекса базы данных
+func (a *API) isUniqueViolation(err error) bool {
+	if err == nil {
+		return false
+	}
+	if translator, ok := a.DB.Dialector.(gorm.ErrorTranslator); ok {
+		err = translator.Translate(err)
+	}
+	return errors.Is(err, gorm.ErrDuplicatedKey)
 }
 
 func (a *API) loginHandler(w http.ResponseWriter, r *http.Request) {
@@ -118,82 +179,281 @@ func (a *API) loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Проверка блокировки по имени пользователя и адресу клиента
+	userKey := userThrottleKey(user.Username)
+	ipKey := ipThrottleKey(a.clientIP(r))
+	retry, err := a.throttleRetryAfter(userKey, ipKey)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if retry > 0 {
+		writeTooManyAttempts(w, retry)
+		return
+	}
+
 	// Получение пользователя из базы данных
 	var storedUser model.User
 	result := a.DB.Where("username = ?", user.Username).First(&storedUser)
+	if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// Проверка пароля. Для несуществующего пользователя сравнение выполняется
+	// с фиктивным хешем, а ответ не отличается от неверного пароля.
+	passwordHash := []byte(storedUser.Password)
 	if result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
-			http.Error(w, "User not found", http.StatusUnauthorized)
-			return
-		} else {
-			http.Error(w, "Database error", http.StatusInternalServerError)
+		passwordHash = dummyPasswordHash
+	}
+	err = bcrypt.CompareHashAndPassword(passwordHash, []byte(user.Password))
+	if err != nil || result.Error != nil {
+		if !a.recordAttempt(w, userKey, a.Config.Auth.Throttle.UserFreeAttempts) ||
+			!a.recordAttempt(w, ipKey, a.Config.Auth.Throttle.IPFreeAttempts) {
 			return
 		}
+		a.audit(r, auditEntry{
+			Action:     auditLoginFailed,
+			TargetType: auditTargetUser,
+			TargetID:   storedUser.ID,
+			Metadata:   map[string]interface{}{"username": user.Username},
+		})
+		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+		return
 	}
 
-	// Проверка пароля
-	err = bcrypt.CompareHashAndPassword([]byte(storedUser.Password), []byte(user.Password))
+	err = a.throttleReset(userKey)
 	if err != nil {
-		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
 
-	// Токен уже должен быть в storedUser.Token после регистрации
-	if storedUser.Token == "" {
-		http.Error(w, "User token not found", http.StatusUnauthorized)
+	// Выдача токенов или запрос второго фактора
+	a.completeLogin(w, r, &storedUser, "password")
+}
+
+// Обновление пары токенов по refresh-токену
+func (a *API) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Отправка токена в ответе
-	response := map[string]string{
-		"token": storedUser.Token,
+	if request.RefreshToken == "" {
+		http.Error(w, "Refresh token is required", http.StatusBadRequest)
+		return
 	}
-	fmt.Print(storedUser.ID)
 
-	json.NewEncoder(w).Encode(response)
+	tokens, err := a.rotateRefreshToken(request.RefreshToken, a.requestDevice(r))
+	if err != nil {
+		if errors.Is(err, errInvalidRefreshToken) || errors.Is(err, errRefreshTokenReused) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(tokens)
 }
 
+// Выход: отзыв текущего токена
 func (a *API) logoutHandler(w http.ResponseWriter, r *http.Request) {
-	// Реализуйте здесь логику выхода пользователя из системы,
-	// например, аннулирование или удаление токена
 	w.Header().Set("Content-Type", "application/json")
+
+	auth := currentAuth(r)
+
+	err := a.revokeToken(auth.User, auth.Claims)
+	if err == nil {
+		err = a.revokeSession(auth.Claims.SessionID)
+	}
+	if err != nil {
+		http.Error(w, "Failed to revoke token", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditLogout, TargetType: auditTargetSession, TargetID: auth.Claims.SessionID})
+
 	response := map[string]string{
 		"message": "Logged out",
 	}
 	json.NewEncoder(w).Encode(response)
 }
 
+// Выход со всех устройств: все ранее выданные токены пользователя становятся недействительными
+func (a *API) logoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user := currentUser(r)
+
+	err := a.DB.Model(user).Update("token_version", gorm.Expr("token_version + 1")).Error
+	if err == nil {
+		err = a.revokeUserSessions(user.ID)
+	}
+	if err != nil {
+		http.Error(w, "Failed to revoke tokens", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditLogoutAll, TargetType: auditTargetUser, TargetID: user.ID})
+
+	response := map[string]string{
+		"message": "Logged out everywhere",
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
 func (a *API) RunServer() {
 	// Создание маршрутизатора mux
 	r := mux.NewRouter()
 	// Настройка маршрутов с использованием mux
 
+	// Публичные маршруты: доступны без аутентификации
 	r.HandleFunc("/register", a.registerHandler).Methods("POST")
 	r.HandleFunc("/login", a.loginHandler).Methods("POST")
-	r.HandleFunc("/logout", a.logoutHandler).Methods("POST")
-
-	r.HandleFunc("/projects/create", a.createProjectHandler).Methods("POST")
-	r.HandleFunc("/projects", a.getProjectsHandler).Methods("GET")
-	r.HandleFunc("/project/{projectname}", a.getProjectHandler).Methods("GET")
-	r.HandleFunc("/project/{projectname}/update", a.updateProjectHandler).Methods("PUT")
-
-	r.HandleFunc("/project/{projectname}/section/create", a.createSectionHandler).Methods("POST")
-	r.HandleFunc("/project/{projectname}/sections", a.getSectionsHandler).Methods("GET")
-	r.HandleFunc("/project/{projectname}/section/{sectionname}", a.getSectionHandler).Methods("GET")
-	r.HandleFunc("/project/{projectname}/section/{sectionname}/update", a.updateSectionHandler).Methods("PUT")
-
-	r.HandleFunc("/project/{projectname}/section/{sectionname}/create", a.createContentHandler).Methods("POST")
-	r.HandleFunc("/project/{projectname}/section/{sectionname}/content", a.getContentHandler).Methods("GET")
-	r.HandleFunc("/project/{projectname}/section/{sectionname}/contents", a.getContentsHandler).Methods("GET")
-	r.HandleFunc("/project/{projectname}/section/{sectionname}/content/update", a.updateContentHandler).Methods("PUT")
+	r.HandleFunc("/login/2fa", a.loginSecondFactorHandler).Methods("POST")
+	r.HandleFunc("/token/refresh", a.refreshTokenHandler).Methods("POST")
+	r.HandleFunc("/password/forgot", a.forgotPasswordHandler).Methods("POST")
+	r.HandleFunc("/password/reset", a.resetPasswordHandler).Methods("POST")
+	r.HandleFunc("/verify-email", a.verifyEmailHandler).Methods("POST")
+	r.HandleFunc("/oidc/{provider}/login", a.oidcLoginHandler).Methods("GET")
+	r.HandleFunc("/oidc/{provider}/callback", a.oidcCallbackHandler).Methods("GET")
+	r.HandleFunc("/.well-known/jwks.json", a.jwksHandler).Methods("GET")
+	r.HandleFunc("/s/{token}", a.sharedProjectHandler).Methods("GET")
+
+	// Файлы локального хранилища
+	if local, ok := a.Storage.(*storage.LocalStorage); ok {
+		r.PathPrefix("/uploads/").Handler(http.StripPrefix("/uploads/", serveUploads(local.Dir())))
+	}
 
-	r.HandleFunc("/templates/create", a.createProjectHandler).Methods("POST")
-	r.HandleFunc("/templates", a.getProjectsHandler).Methods("GET")
-	r.HandleFunc("/template/{templatename}", a.getProjectHandler).Methods("GET")
-	r.HandleFunc("/template/{templatename}/update", a.updateProjectHandler).Methods("PUT")
+	// Защищённые маршруты: пользователь берётся из контекста запроса
+	p := r.NewRoute().Subrouter()
+	p.Use(a.authMiddleware)
+
+	// Управление учётной записью: только по токену сессии
+	s := p.NewRoute().Subrouter()
+	s.Use(a.sessionOnly)
+
+	s.HandleFunc("/logout", a.logoutHandler).Methods("POST")
+	s.HandleFunc("/logout/all", a.logoutAllHandler).Methods("POST")
+	s.HandleFunc("/verify-email/resend", a.resendVerificationHandler).Methods("POST")
+	s.HandleFunc("/me", a.meHandler).Methods("GET")
+	s.HandleFunc("/me", a.deleteAccountHandler).Methods("DELETE")
+	s.HandleFunc("/me/deletion", a.cancelAccountDeletionHandler).Methods("DELETE")
+	s.HandleFunc("/me/export", a.exportAccountHandler).Methods("GET")
+	s.HandleFunc("/me/password", a.changePasswordHandler).Methods("POST")
+	s.HandleFunc("/me/2fa/totp/setup", a.totpSetupHandler).Methods("POST")
+	s.HandleFunc("/me/2fa/totp/confirm", a.totpConfirmHandler).Methods("POST")
+	s.HandleFunc("/me/2fa/totp/disable", a.totpDisableHandler).Methods("POST")
+	s.HandleFunc("/me/2fa/recovery-codes", a.recoveryCodesHandler).Methods("POST")
+	s.HandleFunc("/me/tokens", a.getPersonalTokensHandler).Methods("GET")
+	s.HandleFunc("/me/tokens", a.createPersonalTokenHandler).Methods("POST")
+	s.HandleFunc("/me/tokens/{id}", a.revokePersonalTokenHandler).Methods("DELETE")
+	s.HandleFunc("/me/sessions", a.getSessionsHandler).Methods("GET")
+	s.HandleFunc("/me/sessions", a.revokeOtherSessionsHandler).Methods("DELETE")
+	s.HandleFunc("/me/sessions/{id}", a.revokeSessionHandler).Methods("DELETE")
+	s.HandleFunc("/me/identities", a.getIdentitiesHandler).Methods("GET")
+	s.HandleFunc("/me/security-events", a.getSecurityEventsHandler).Methods("GET")
+	s.HandleFunc("/me/invitations", a.getMyInvitationsHandler).Methods("GET")
+	s.HandleFunc("/me/invitations/{id}/accept", a.answerInvitationHandler(true)).Methods("POST")
+	s.HandleFunc("/me/invitations/{id}/decline", a.answerInvitationHandler(false)).Methods("POST")
+	s.HandleFunc("/invitations/accept", a.acceptInvitationTokenHandler).Methods("POST")
+	s.HandleFunc("/me/identities/{provider}/link", a.linkIdentityHandler).Methods("POST")
+	s.HandleFunc("/me/identities/{id}", a.unlinkIdentityHandler).Methods("DELETE")
+
+	// Администрирование
+	admin := s.PathPrefix("/admin").Subrouter()
+	admin.Use(a.requireRole(model.RoleAdmin))
+
+	admin.HandleFunc("/audit", a.getAuditEventsHandler).Methods("GET")
+	admin.HandleFunc("/lockouts", a.getLockoutsHandler).Methods("GET")
+	admin.HandleFunc("/lockouts/{id}", a.clearLockoutHandler).Methods("DELETE")
+	admin.HandleFunc("/users", a.getUsersHandler).Methods("GET")
+	admin.HandleFunc("/users/{id}", a.getUserHandler).Methods("GET")
+	admin.HandleFunc("/users/{id}", a.deleteUserHandler).Methods("DELETE")
+	admin.HandleFunc("/users/{id}/role", a.setUserRoleHandler).Methods("PUT")
+	admin.HandleFunc("/users/{id}/disable", a.disableUserHandler).Methods("POST")
+	admin.HandleFunc("/users/{id}/enable", a.enableUserHandler).Methods("POST")
+	admin.HandleFunc("/users/{id}/password-reset", a.forcePasswordResetHandler).Methods("POST")
+
+	// Данные пользователя: доступны также по персональному токену с нужными правами
+	p.HandleFunc("/projects/create", a.requireScope(scopeProjectsWrite, a.requireVerified(actionCreateProject, a.createProjectHandler))).Methods("POST")
+	p.HandleFunc("/projects", a.requireScope(scopeProjectsRead, a.getProjectsHandler)).Methods("GET")
+	p.HandleFunc("/projects/import", a.requireScope(scopeProjectsWrite, a.requireVerified(actionCreateProject, a.importProjectHandler))).Methods("POST")
+	p.HandleFunc("/project/{projectname}", a.requireScope(scopeProjectsRead, a.getProjectHandler)).Methods("GET")
+	p.HandleFunc("/project/{projectname}/update", a.requireScope(scopeProjectsWrite, a.updateProjectHandler)).Methods("PUT")
+	p.HandleFunc("/project/{projectname}", a.requireScope(scopeProjectsWrite, a.deleteProjectHandler)).Methods("DELETE")
+	p.HandleFunc("/project/{projectname}/snapshots", a.requireScope(scopeProjectsRead, a.getSnapshotsHandler)).Methods("GET")
+	p.HandleFunc("/project/{projectname}/snapshots", a.requireScope(scopeProjectsWrite, a.createSnapshotHandler)).Methods("POST")
+	p.HandleFunc("/project/{projectname}/snapshots/{id}", a.requireScope(scopeProjectsRead, a.requireScope(scopeContentRead, a.getSnapshotHandler))).Methods("GET")
+	p.HandleFunc("/project/{projectname}/snapshots/{id}/diff", a.requireScope(scopeProjectsRead, a.requireScope(scopeContentRead, a.diffSnapshotHandler))).Methods("GET")
+	p.HandleFunc("/project/{projectname}/snapshots/{id}/rollback", a.requireScope(scopeContentWrite, a.rollbackSnapshotHandler)).Methods("POST")
+	p.HandleFunc("/project/{projectname}/export", a.requireScope(scopeProjectsRead, a.requireScope(scopeContentRead, a.exportProjectHandler))).Methods("GET")
+	p.HandleFunc("/project/{projectname}/duplicate", a.requireScope(scopeProjectsWrite, a.requireVerified(actionCreateProject, a.duplicateProjectHandler))).Methods("POST")
+	p.HandleFunc("/project/{projectname}/transfer", a.requireScope(scopeProjectsWrite, a.transferProjectHandler)).Methods("POST")
+
+	p.HandleFunc("/project/{projectname}/members", a.requireScope(scopeProjectsRead, a.getMembersHandler)).Methods("GET")
+	p.HandleFunc("/project/{projectname}/members/{userid}", a.requireScope(scopeProjectsWrite, a.updateMemberHandler)).Methods("PUT")
+	p.HandleFunc("/project/{projectname}/members/{userid}", a.requireScope(scopeProjectsWrite, a.removeMemberHandler)).Methods("DELETE")
+	p.HandleFunc("/project/{projectname}/invitations", a.requireScope(scopeProjectsRead, a.getInvitationsHandler)).Methods("GET")
+	p.HandleFunc("/project/{projectname}/invitations", a.requireScope(scopeProjectsWrite, a.requireVerified(actionShare, a.createInvitationHandler))).Methods("POST")
+	p.HandleFunc("/project/{projectname}/invitations/{id}", a.requireScope(scopeProjectsWrite, a.revokeInvitationHandler)).Methods("DELETE")
+	p.HandleFunc("/project/{projectname}/share-links", a.requireScope(scopeProjectsRead, a.getShareLinksHandler)).Methods("GET")
+	p.HandleFunc("/project/{projectname}/share-links", a.requireScope(scopeProjectsWrite, a.requireVerified(actionPublish, a.createShareLinkHandler))).Methods("POST")
+	p.HandleFunc("/project/{projectname}/share-links/{id}", a.requireScope(scopeProjectsWrite, a.revokeShareLinkHandler)).Methods("DELETE")
+
+	p.HandleFunc("/project/{projectname}/section/create", a.requireScope(scopeContentWrite, a.createSectionHandler)).Methods("POST")
+	p.HandleFunc("/project/{projectname}/sections", a.requireScope(scopeContentRead, a.getSectionsHandler)).Methods("GET")
+	p.HandleFunc("/project/{projectname}/section/{sectionname}", a.requireScope(scopeContentRead, a.getSectionHandler)).Methods("GET")
+	p.HandleFunc("/project/{projectname}/section/{sectionname}/update", a.requireScope(scopeContentWrite, a.updateSectionHandler)).Methods("PUT")
+	p.HandleFunc("/project/{projectname}/section/{sectionname}", a.requireScope(scopeContentWrite, a.deleteSectionHandler)).Methods("DELETE")
+
+	p.HandleFunc("/project/{projectname}/section/{sectionname}/create", a.requireScope(scopeContentWrite, a.createContentHandler)).Methods("POST")
+	p.HandleFunc("/project/{projectname}/section/{sectionname}/contents", a.requireScope(scopeContentRead, a.getContentsHandler)).Methods("GET")
+	p.HandleFunc("/project/{projectname}/section/{sectionname}/content/{contentid}", a.requireScope(scopeContentRead, a.getContentHandler)).Methods("GET")
+	p.HandleFunc("/project/{projectname}/section/{sectionname}/content/{contentid}/update", a.requireScope(scopeContentWrite, a.updateContentHandler)).Methods("PUT")
+	p.HandleFunc("/project/{projectname}/section/{sectionname}/content/{contentid}", a.requireScope(scopeContentWrite, a.deleteContentHandler)).Methods("DELETE")
+
+	p.HandleFunc("/trash", a.requireScope(scopeProjectsRead, a.getTrashHandler)).Methods("GET")
+	p.HandleFunc("/trash/projects/{id}/restore", a.requireScope(scopeProjectsWrite, a.restoreProjectHandler)).Methods("POST")
+	p.HandleFunc("/trash/projects/{id}", a.requireScope(scopeProjectsWrite, a.purgeProjectHandler)).Methods("DELETE")
+	p.HandleFunc("/trash/sections/{id}/restore", a.requireScope(scopeContentWrite, a.restoreSectionHandler)).Methods("POST")
+	p.HandleFunc("/trash/sections/{id}", a.requireScope(scopeContentWrite, a.purgeSectionHandler)).Methods("DELETE")
+	p.HandleFunc("/trash/contents/{id}/restore", a.requireScope(scopeContentWrite, a.restoreContentHandler)).Methods("POST")
+	p.HandleFunc("/trash/contents/{id}", a.requireScope(scopeContentWrite, a.purgeContentHandler)).Methods("DELETE")
+
+	p.HandleFunc("/media", a.requireScope(scopeContentWrite, a.uploadMediaHandler)).Methods("POST")
+	p.HandleFunc("/media", a.requireScope(scopeContentRead, a.getMediaHandler)).Methods("GET")
+	p.HandleFunc("/media/{id}", a.requireScope(scopeContentWrite, a.deleteMediaHandler)).Methods("DELETE")
+
+	p.HandleFunc("/templates/create", a.requireScope(scopeProjectsWrite, a.requireVerified(actionCreateProject, a.createTemplateHandler))).Methods("POST")
+	p.HandleFunc("/templates", a.requireScope(scopeProjectsRead, a.getTemplatesHandler)).Methods("GET")
+	p.HandleFunc("/template/{templatename}", a.requireScope(scopeProjectsRead, a.getTemplateHandler)).Methods("GET")
+	p.HandleFunc("/template/{templatename}/update", a.requireScope(scopeProjectsWrite, a.updateTemplateHandler)).Methods("PUT")
+	p.HandleFunc("/template/{templatename}", a.requireScope(scopeProjectsWrite, a.deleteTemplateHandler)).Methods("DELETE")
+	p.HandleFunc("/template/{templatename}/transfer", a.requireScope(scopeProjectsWrite, a.transferTemplateHandler)).Methods("POST")
+
+	p.HandleFunc("/orgs", a.requireScope(scopeProjectsRead, a.getOrganizationsHandler)).Methods("GET")
+	p.HandleFunc("/orgs", a.requireScope(scopeProjectsWrite, a.requireVerified(actionCreateProject, a.createOrganizationHandler))).Methods("POST")
+	p.HandleFunc("/orgs/{orgname}", a.requireScope(scopeProjectsRead, a.getOrganizationHandler)).Methods("GET")
+	p.HandleFunc("/orgs/{orgname}", a.requireScope(scopeProjectsWrite, a.updateOrganizationHandler)).Methods("PUT")
+	p.HandleFunc("/orgs/{orgname}", a.requireScope(scopeProjectsWrite, a.deleteOrganizationHandler)).Methods("DELETE")
+	p.HandleFunc("/orgs/{orgname}/members", a.requireScope(scopeProjectsRead, a.getOrganizationMembersHandler)).Methods("GET")
+	p.HandleFunc("/orgs/{orgname}/members", a.requireScope(scopeProjectsWrite, a.requireVerified(actionShare, a.addOrganizationMemberHandler))).Methods("POST")
+	p.HandleFunc("/orgs/{orgname}/members/{userid}", a.requireScope(scopeProjectsWrite, a.updateOrganizationMemberHandler)).Methods("PUT")
+	p.HandleFunc("/orgs/{orgname}/members/{userid}", a.requireScope(scopeProjectsWrite, a.removeOrganizationMemberHandler)).Methods("DELETE")
 
 	http.Handle("/", r)
 
+	// Фоновые задачи обслуживания
+	a.startJobs()
+
 	log.Println("Server started on port 8080")
 	log.Fatal(http.ListenAndServe(":8080", nil))
 }
@@ -0,0 +1,481 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+)
+
+// Версия формата архива проекта. Архивы другой версии не импортируются.
+const projectArchiveVersion = 1
+
+// Ограничения импортируемого архива
+const (
+	maxImportSize       = 256 << 20
+	maxArchiveDocument  = 64 << 20
+	maxArchiveEntries   = 10000   // Файлов в архиве и записей в media.json
+	maxArchiveExpanded  = 1 << 30 // Суммарный размер файлов после распаковки
+	projectArchiveMedia = "media/"
+)
+
+// Архив не прошёл проверку; текст ошибки показывается клиенту
+var errInvalidArchive = errors.New("invalid archive")
+
+// Файл manifest.json
+type archiveManifest struct {
+	SchemaVersion int       `json:"schema_version"`
+	ExportedAt    time.Time `json:"exported_at"`
+}
+
+// Файл project.json. Идентификаторы разделов и содержимого - из исходной
+// базы; при импорте объекты получают новые.
+type archiveProject struct {
+	Name       string           `json:"name"`
+	Slug       string           `json:"slug"`
+	IsTemplate bool             `json:"is_template"`
+	Visibility string           `json:"visibility"`
+	Tags       []string         `json:"tags"`
+	Sections   []archiveSection `json:"sections"`
+}
+
+type archiveSection struct {
+	ID       uint             `json:"id"`
+	Title    string           `json:"title"`
+	Slug     string           `json:"slug"`
+	Contents []archiveContent `json:"contents"`
+}
+
+type archiveContent struct {
+	ID   uint   `json:"id"`
+	Type string `json:"type"`
+	Data string `json:"data"`
+}
+
+// Элемент media.json: файл из media/ и ключ и адрес, по которым
+// на него ссылается содержимое в исходной базе
+type archiveMedia struct {
+	Key         string `json:"key"`
+	URL         string `json:"url"`
+	Path        string `json:"path"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+// Разделы проекта с содержимым в порядке создания
+func projectTree(db *gorm.DB, projectID uint) ([]archiveSection, error) {
+	var sections []model.Section
+	err := db.Where("project_id = ?", projectID).Preload("Contents", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Order("id").Find(&sections).Error
+	if err != nil {
+		return nil, err
+	}
+
+	tree := make([]archiveSection, 0, len(sections))
+	for _, section := range sections {
+		entry := archiveSection{ID: section.ID, Title: section.Title, Slug: section.Slug, Contents: make([]archiveContent, 0, len(section.Contents))}
+		for _, content := range section.Contents {
+			entry.Contents = append(entry.Contents, archiveContent{ID: content.ID, Type: content.Type, Data: content.Data})
+		}
+		tree = append(tree, entry)
+	}
+	return tree, nil
+}
+
+// Экспорт проекта в zip-архив
+func (a *API) exportProjectHandler(w http.ResponseWriter, r *http.Request) {
+	project, err := a.requestProject(r, model.ProjectRoleViewer)
+	if err != nil {
+		writeResolveError(w, err, "Project not found")
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditProjectExported, TargetType: auditTargetProject, TargetID: project.ID})
+
+	filename := fmt.Sprintf("%s-%s.zip", project.Slug, time.Now().UTC().Format("20060102"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	// Архив пишется сразу в ответ; после начала записи ошибку можно только залогировать
+	err = a.ExportProject(r.Context(), w, project.ID)
+	if err != nil {
+		log.Printf("project export %d: %v", project.ID, err)
+	}
+}
+
+// Запись проекта с разделами, содержимым, метками и файлами,
+// на которые ссылается содержимое, в zip-архив
+func (a *API) ExportProject(ctx context.Context, w io.Writer, projectID uint) error {
+	var project model.Project
+	err := a.DB.First(&project, projectID).Error
+	if err != nil {
+		return err
+	}
+	sections, err := projectTree(a.DB, project.ID)
+	if err != nil {
+		return err
+	}
+	tags, err := a.projectTags([]uint{project.ID})
+	if err != nil {
+		return err
+	}
+	files, err := a.referencedMedia(project.ID)
+	if err != nil {
+		return err
+	}
+
+	document := archiveProject{
+		Name:       project.Name,
+		Slug:       project.Slug,
+		IsTemplate: project.IsTemplate,
+		Visibility: project.Visibility,
+		Tags:       tags[project.ID],
+		Sections:   sections,
+	}
+
+	mediaEntries := make([]archiveMedia, 0, len(files))
+	for _, file := range files {
+		mediaEntries = append(mediaEntries, archiveMedia{
+			Key:         file.Key,
+			URL:         a.Storage.URL(file.Key),
+			Path:        projectArchiveMedia + path.Base(file.Key),
+			Filename:    file.Filename,
+			ContentType: file.ContentType,
+			Size:        file.Size,
+		})
+	}
+
+	archive := zip.NewWriter(w)
+	err = a.writeExport(ctx, archive, map[string]interface{}{
+		"manifest.json": archiveManifest{SchemaVersion: projectArchiveVersion, ExportedAt: time.Now().UTC()},
+		"project.json":  document,
+		"media.json":    mediaEntries,
+	}, files)
+	if err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// Импорт проекта из zip-архива (тело запроса или поле file формы).
+// Параметр organization создаёт проект в указанной организации.
+func (a *API) importProjectHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user := currentUser(r)
+	var org *model.Organization
+	if name := r.URL.Query().Get("organization"); name != "" {
+		var role string
+		var err error
+		org, role, err = a.resolveOrganization(user, name, model.OrgRoleMember)
+		if err != nil {
+			writeResolveError(w, err, "Organization not found")
+			return
+		}
+		if !canCreateInOrganization(org, role) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	// Архив сохраняется во временный файл: zip читается с произвольного места
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "File is required", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	tmp, err := os.CreateTemp("", "project-import-*.zip")
+	if err != nil {
+		http.Error(w, "Failed to read archive", http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, body)
+	if err != nil {
+		http.Error(w, "Archive is too large or unreadable", http.StatusRequestEntityTooLarge)
+		return
+	}
+	archive, err := zip.NewReader(tmp, size)
+	if err != nil {
+		http.Error(w, "Invalid archive", http.StatusBadRequest)
+		return
+	}
+
+	project, err := a.ImportProject(r.Context(), archive, user, org)
+	if errors.Is(err, errInvalidArchive) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("project import: %v", err)
+		http.Error(w, "Failed to import project", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditProjectImported, TargetType: auditTargetProject, TargetID: project.ID, After: project})
+
+	a.writeProject(w, r, project, http.StatusCreated)
+}
+
+// Создание проекта из архива у пользователя или в организации org.
+// Разделы и содержимое получают новые идентификаторы, совпадающие
+// название и адрес получают номер. Проект создаётся в одной транзакции;
+// при ошибке загруженные файлы удаляются.
+func (a *API) ImportProject(ctx context.Context, archive *zip.Reader, user *model.User, org *model.Organization) (*model.Project, error) {
+	if len(archive.File) > maxArchiveEntries {
+		return nil, fmt.Errorf("%w: too many files", errInvalidArchive)
+	}
+	// Размеры из заголовков zip: при чтении файл длиннее заявленного
+	// размера даёт ошибку
+	files := make(map[string]*zip.File, len(archive.File))
+	var expanded uint64
+	for _, file := range archive.File {
+		files[file.Name] = file
+		expanded += file.UncompressedSize64
+		if expanded > maxArchiveExpanded {
+			return nil, fmt.Errorf("%w: archive is too large when unpacked", errInvalidArchive)
+		}
+	}
+
+	var manifest archiveManifest
+	if err := readArchiveDocument(files, "manifest.json", &manifest); err != nil {
+		return nil, err
+	}
+	if manifest.SchemaVersion != projectArchiveVersion {
+		return nil, fmt.Errorf("%w: unsupported schema version %d", errInvalidArchive, manifest.SchemaVersion)
+	}
+	var document archiveProject
+	if err := readArchiveDocument(files, "project.json", &document); err != nil {
+		return nil, err
+	}
+	var mediaEntries []archiveMedia
+	if err := readArchiveDocument(files, "media.json", &mediaEntries); err != nil {
+		return nil, err
+	}
+
+	project, err := newArchiveProject(&document, user, org)
+	if err != nil {
+		return nil, err
+	}
+	if len(mediaEntries) > maxArchiveEntries {
+		return nil, fmt.Errorf("%w: too many media files", errInvalidArchive)
+	}
+	for _, entry := range mediaEntries {
+		file := files[entry.Path]
+		if file == nil || !strings.HasPrefix(entry.Path, projectArchiveMedia) || entry.Key == "" {
+			return nil, fmt.Errorf("%w: media file %q is missing", errInvalidArchive, entry.Path)
+		}
+		if file.UncompressedSize64 > maxUploadSize {
+			return nil, fmt.Errorf("%w: media file %q is too large", errInvalidArchive, entry.Path)
+		}
+	}
+
+	// Файлы загружаются до транзакции: хранилище в ней не участвует.
+	// Записи с одним путём ссылаются на один загруженный файл.
+	uploaded := make([]model.Media, 0, len(mediaEntries))
+	cleanup := func() {
+		for _, media := range uploaded {
+			a.Storage.Delete(ctx, media.Key)
+		}
+	}
+	uploadedKeys := make(map[string]string, len(mediaEntries))
+	replacements := make(map[string]string, 2*len(mediaEntries))
+	for _, entry := range mediaEntries {
+		key, ok := uploadedKeys[entry.Path]
+		if !ok {
+			media, err := a.importArchiveMedia(ctx, files[entry.Path], &entry, user)
+			if err != nil {
+				cleanup()
+				return nil, err
+			}
+			uploaded = append(uploaded, *media)
+			uploadedKeys[entry.Path] = media.Key
+			key = media.Key
+		}
+		replacements[entry.Key] = key
+		if entry.URL != "" {
+			replacements[entry.URL] = a.Storage.URL(key)
+		}
+	}
+	keys := keyReplacer(replacements)
+	for _, section := range project.Sections {
+		for i := range section.Contents {
+			section.Contents[i].Data = keys.Replace(section.Contents[i].Data)
+		}
+	}
+
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		scope := projectSlugScope(tx, project)
+		project.Name, err = freeProjectName(scope, project.Name)
+		if err != nil {
+			return err
+		}
+		// Адрес из архива сохраняется, если он свободен
+		preferred := project.Slug
+		if preferred == "" {
+			preferred = project.Name
+		}
+		project.Slug, err = freeSlug(scope, preferred, model.SlugKindProject)
+		if err != nil {
+			return err
+		}
+		assignNewSectionSlugs(project.Sections)
+
+		err = tx.Session(&gorm.Session{CreateBatchSize: duplicateBatchSize}).Create(project).Error
+		if err != nil {
+			return err
+		}
+		if err := replaceProjectTags(tx, project.ID, project.Tags); err != nil {
+			return err
+		}
+		if len(uploaded) > 0 {
+			if err := tx.Create(&uploaded).Error; err != nil {
+				return err
+			}
+		}
+		if project.OrganizationID == nil {
+			return nil
+		}
+		return tx.Create(&model.ProjectMember{ProjectID: project.ID, UserID: user.ID, Role: model.ProjectRoleOwner}).Error
+	})
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	return project, nil
+}
+
+// Проверка project.json и построение нового проекта без идентификаторов
+func newArchiveProject(document *archiveProject, user *model.User, org *model.Organization) (*model.Project, error) {
+	name := strings.TrimSpace(document.Name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: project name is required", errInvalidArchive)
+	}
+	tags, err := normalizeTags(document.Tags)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidArchive, err)
+	}
+
+	project := &model.Project{
+		UserID:     user.ID,
+		Name:       name,
+		Slug:       document.Slug,
+		IsTemplate: document.IsTemplate,
+		Visibility: model.VisibilityPrivate,
+		Tags:       tags,
+		Sections:   make([]*model.Section, 0, len(document.Sections)),
+	}
+	if org != nil {
+		project.OrganizationID = &org.ID
+		project.Visibility = org.DefaultVisibility
+		if isVisibility(document.Visibility) {
+			project.Visibility = document.Visibility
+		}
+	}
+
+	sectionIDs := make(map[uint]bool, len(document.Sections))
+	contentIDs := make(map[uint]bool)
+	for _, entry := range document.Sections {
+		if sectionIDs[entry.ID] {
+			return nil, fmt.Errorf("%w: duplicate section id %d", errInvalidArchive, entry.ID)
+		}
+		sectionIDs[entry.ID] = true
+
+		section := &model.Section{Title: entry.Title, Slug: entry.Slug, Contents: make([]model.Content, 0, len(entry.Contents))}
+		for _, content := range entry.Contents {
+			if contentIDs[content.ID] {
+				return nil, fmt.Errorf("%w: duplicate content id %d", errInvalidArchive, content.ID)
+			}
+			contentIDs[content.ID] = true
+			if content.Type == "" {
+				return nil, fmt.Errorf("%w: content %d has no type", errInvalidArchive, content.ID)
+			}
+			section.Contents = append(section.Contents, model.Content{Type: content.Type, Data: content.Data})
+		}
+		project.Sections = append(project.Sections, section)
+	}
+	return project, nil
+}
+
+// Загрузка файла из архива в хранилище. Тип определяется по содержимому.
+func (a *API) importArchiveMedia(ctx context.Context, file *zip.File, entry *archiveMedia, user *model.User) (*model.Media, error) {
+	body, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidArchive, err)
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, maxUploadSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidArchive, err)
+	}
+	if len(data) > maxUploadSize {
+		return nil, fmt.Errorf("%w: media file %q is too large", errInvalidArchive, entry.Path)
+	}
+	contentType := http.DetectContentType(data)
+	if !allowedMediaTypes[contentType] {
+		return nil, fmt.Errorf("%w: media file %q has unsupported type", errInvalidArchive, entry.Path)
+	}
+
+	filename := entry.Filename
+	if filename == "" {
+		filename = path.Base(entry.Path)
+	}
+	key, err := newMediaKey(user.ID, filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.Storage.Put(ctx, key, bytes.NewReader(data), contentType); err != nil {
+		return nil, err
+	}
+	return &model.Media{
+		UserID:      user.ID,
+		Key:         key,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+	}, nil
+}
+
+// Чтение JSON-файла архива
+func readArchiveDocument(files map[string]*zip.File, name string, document interface{}) error {
+	file := files[name]
+	if file == nil {
+		return fmt.Errorf("%w: %s is missing", errInvalidArchive, name)
+	}
+	body, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", errInvalidArchive, name, err)
+	}
+	defer body.Close()
+
+	err = json.NewDecoder(io.LimitReader(body, maxArchiveDocument)).Decode(document)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", errInvalidArchive, name, err)
+	}
+	return nil
+}
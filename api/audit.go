@@ -0,0 +1,371 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+)
+
+// Действия журнала аудита
+const (
+	auditRegister           = "auth.register"
+	auditLogin              = "auth.login"
+	auditLoginFailed        = "auth.login_failed"
+	auditSecondFactorFailed = "auth.2fa_failed"
+	auditLogout             = "auth.logout"
+	auditLogoutAll          = "auth.logout_all"
+	auditPasswordChanged    = "auth.password_changed"
+	auditPasswordReset      = "auth.password_reset"
+	auditEmailVerified      = "auth.email_verified"
+	auditTOTPEnabled        = "auth.2fa_enabled"
+	auditTOTPDisabled       = "auth.2fa_disabled"
+	auditRecoveryCodes      = "auth.recovery_codes_regenerated"
+	auditTokenCreated       = "auth.token_created"
+	auditTokenRevoked       = "auth.token_revoked"
+	auditSessionRevoked     = "auth.session_revoked"
+	auditIdentityLinked     = "auth.identity_linked"
+	auditIdentityUnlinked   = "auth.identity_unlinked"
+
+	auditAccountExported         = "account.exported"
+	auditAccountDeletionSchedule = "account.deletion_scheduled"
+	auditAccountDeletionCancel   = "account.deletion_cancelled"
+
+	auditAdminUserRole      = "admin.user_role_changed"
+	auditAdminUserDisabled  = "admin.user_disabled"
+	auditAdminUserEnabled   = "admin.user_enabled"
+	auditAdminUserDeleted   = "admin.user_deleted"
+	auditAdminPasswordReset = "admin.password_reset_required"
+	auditAdminLockoutClear  = "admin.lockout_cleared"
+
+	auditProjectCreated     = "project.created"
+	auditProjectDuplicated  = "project.duplicated"
+	auditProjectExported    = "project.exported"
+	auditProjectImported    = "project.imported"
+	auditProjectRolledBack  = "project.rolled_back"
+	auditSnapshotCreated    = "project.snapshot_created"
+	auditProjectUpdated     = "project.updated"
+	auditProjectTransferred = "project.transferred"
+	auditProjectDeleted     = "project.deleted"
+	auditProjectRestored    = "project.restored"
+	auditProjectPurged      = "project.purged"
+	auditSectionCreated     = "section.created"
+	auditSectionUpdated     = "section.updated"
+	auditSectionDeleted     = "section.deleted"
+	auditSectionRestored    = "section.restored"
+	auditSectionPurged      = "section.purged"
+	auditContentCreated     = "content.created"
+	auditContentUpdated     = "content.updated"
+	auditContentDeleted     = "content.deleted"
+	auditContentRestored    = "content.restored"
+	auditContentPurged      = "content.purged"
+	auditMediaUploaded      = "media.uploaded"
+	auditMediaDeleted       = "media.deleted"
+
+	auditMemberRoleChanged  = "member.role_changed"
+	auditMemberRemoved      = "member.removed"
+	auditInvitationCreated  = "invitation.created"
+	auditInvitationRevoked  = "invitation.revoked"
+	auditInvitationAccepted = "invitation.accepted"
+	auditInvitationDeclined = "invitation.declined"
+	auditShareLinkCreated   = "share_link.created"
+	auditShareLinkRevoked   = "share_link.revoked"
+
+	auditOrgCreated       = "org.created"
+	auditOrgUpdated       = "org.updated"
+	auditOrgDeleted       = "org.deleted"
+	auditOrgMemberAdded   = "org.member_added"
+	auditOrgMemberRole    = "org.member_role_changed"
+	auditOrgMemberRemoved = "org.member_removed"
+)
+
+// Типы объектов журнала аудита
+const (
+	auditTargetUser         = "user"
+	auditTargetProject      = "project"
+	auditTargetOrganization = "organization"
+	auditTargetSection      = "section"
+	auditTargetContent      = "content"
+	auditTargetMedia        = "media"
+	auditTargetToken        = "token"
+	auditTargetSession      = "session"
+	auditTargetIdentity     = "identity"
+	auditTargetLockout      = "lockout"
+)
+
+// Поля, которые не попадают в журнал или попадают без значения
+var (
+	auditSkippedFields  = map[string]bool{"ID": true, "CreatedAt": true, "UpdatedAt": true, "DeletedAt": true}
+	auditRedactedFields = map[string]bool{"Password": true, "TOTPSecret": true, "TOTPPendingSecret": true}
+)
+
+// Событие для журнала аудита. Before и After - состояние объекта
+// до и после изменения; в журнал записываются только изменившиеся поля.
+type auditEntry struct {
+	ActorID    *uint // По умолчанию - пользователь запроса
+	Action     string
+	TargetType string
+	TargetID   uint
+	Before     interface{}
+	After      interface{}
+	Metadata   map[string]interface{}
+}
+
+// Запись события в журнал аудита. Ошибка записи не прерывает запрос
+// и только логируется.
+func (a *API) audit(r *http.Request, entry auditEntry) {
+	event := model.AuditEvent{
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+	}
+
+	event.ActorID = entry.ActorID
+	if event.ActorID == nil {
+		if auth := currentAuth(r); auth != nil {
+			event.ActorID = &auth.User.ID
+		}
+	}
+
+	if r != nil {
+		device := a.requestDevice(r)
+		event.IP = device.IP
+		event.UserAgent = device.UserAgent
+	}
+
+	if changes := auditDiff(entry.Before, entry.After); len(changes) > 0 {
+		data, _ := json.Marshal(changes)
+		event.Changes = string(data)
+	}
+	if len(entry.Metadata) > 0 {
+		data, _ := json.Marshal(entry.Metadata)
+		event.Metadata = string(data)
+	}
+
+	if err := a.DB.Create(&event).Error; err != nil {
+		log.Printf("audit %s: %v", entry.Action, err)
+	}
+}
+
+// Изменение поля
+type auditChange struct {
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// Разница между двумя состояниями объекта. Связанные объекты (вложенные
+// массивы и объекты) не сравниваются, секреты заменяются отметкой.
+func auditDiff(before, after interface{}) map[string]auditChange {
+	beforeFields := auditFields(before)
+	afterFields := auditFields(after)
+
+	changes := make(map[string]auditChange)
+	for name, value := range afterFields {
+		old, existed := beforeFields[name]
+		if existed && reflect.DeepEqual(old, value) {
+			continue
+		}
+		if !existed && isEmptyAuditValue(value) {
+			continue
+		}
+		changes[name] = auditChange{Before: old, After: value}
+	}
+	for name, old := range beforeFields {
+		if _, ok := afterFields[name]; !ok && !isEmptyAuditValue(old) {
+			changes[name] = auditChange{Before: old}
+		}
+	}
+
+	for name := range changes {
+		if auditRedactedFields[name] {
+			changes[name] = auditChange{After: "[redacted]"}
+		}
+	}
+	return changes
+}
+
+// Пустые значения не записываются при создании и удалении объекта
+func isEmptyAuditValue(value interface{}) bool {
+	return value == nil || value == ""
+}
+
+// Поля объекта в виде JSON-значений
+func auditFields(value interface{}) map[string]interface{} {
+	if value == nil || reflect.ValueOf(value).IsZero() {
+		return nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+
+	for name, field := range fields {
+		switch field.(type) {
+		case []interface{}, map[string]interface{}:
+			delete(fields, name)
+			continue
+		}
+		if auditSkippedFields[name] {
+			delete(fields, name)
+		}
+	}
+	return fields
+}
+
+// Запись журнала в ответах API
+type auditEventView struct {
+	ID         uint            `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	ActorID    *uint           `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   uint            `json:"target_id"`
+	IP         string          `json:"ip"`
+	UserAgent  string          `json:"user_agent"`
+	Changes    json.RawMessage `json:"changes,omitempty"`
+	Metadata   json.RawMessage `json:"metadata,omitempty"`
+}
+
+func newAuditEventView(event *model.AuditEvent) auditEventView {
+	view := auditEventView{
+		ID:         event.ID,
+		CreatedAt:  event.CreatedAt,
+		ActorID:    event.ActorID,
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		IP:         event.IP,
+		UserAgent:  event.UserAgent,
+	}
+	if event.Changes != "" {
+		view.Changes = json.RawMessage(event.Changes)
+	}
+	if event.Metadata != "" {
+		view.Metadata = json.RawMessage(event.Metadata)
+	}
+	return view
+}
+
+// Размер страницы журнала
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 500
+)
+
+// Журнал аудита для администратора. Фильтры: actor_id, action (точное
+// значение или префикс с "." на конце, например "auth."), target_type,
+// target_id, since, until (RFC 3339). Страницы листаются параметром
+// before_id: в ответе next_before_id для следующей страницы.
+func (a *API) getAuditEventsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.URL.Query()
+	query := a.DB.Model(&model.AuditEvent{})
+
+	if actorID := params.Get("actor_id"); actorID != "" {
+		id, err := strconv.ParseUint(actorID, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid actor_id", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("actor_id = ?", id)
+	}
+	if action := params.Get("action"); action != "" {
+		if strings.HasSuffix(action, ".") {
+			query = query.Where("action LIKE ?", action+"%")
+		} else {
+			query = query.Where("action = ?", action)
+		}
+	}
+	if targetType := params.Get("target_type"); targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+	if targetID := params.Get("target_id"); targetID != "" {
+		id, err := strconv.ParseUint(targetID, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid target_id", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("target_id = ?", id)
+	}
+	for _, bound := range []struct{ param, condition string }{
+		{"since", "created_at >= ?"},
+		{"until", "created_at < ?"},
+	} {
+		value := params.Get(bound.param)
+		if value == "" {
+			continue
+		}
+		moment, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			http.Error(w, "Invalid "+bound.param, http.StatusBadRequest)
+			return
+		}
+		query = query.Where(bound.condition, moment)
+	}
+
+	a.writeAuditPage(w, r, query)
+}
+
+// События безопасности текущего пользователя: его собственные действия
+// со входом и учётной записью и действия администраторов над ней
+func (a *API) getSecurityEventsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user := currentUser(r)
+	query := a.DB.Model(&model.AuditEvent{}).
+		Where("(actor_id = ? AND (action LIKE 'auth.%' OR action LIKE 'account.%')) OR "+
+			"(target_type = ? AND target_id = ?)", user.ID, auditTargetUser, user.ID)
+
+	a.writeAuditPage(w, r, query)
+}
+
+// Страница журнала, от новых записей к старым
+func (a *API) writeAuditPage(w http.ResponseWriter, r *http.Request, query *gorm.DB) {
+	params := r.URL.Query()
+
+	limit, err := queryInt(params.Get("limit"), defaultAuditLimit)
+	if err != nil || limit <= 0 || limit > maxAuditLimit {
+		http.Error(w, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+	if beforeID := params.Get("before_id"); beforeID != "" {
+		id, err := strconv.ParseUint(beforeID, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid before_id", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("id < ?", id)
+	}
+
+	var events []model.AuditEvent
+	err = query.Order("id DESC").Limit(limit).Find(&events).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch audit events", http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]auditEventView, 0, len(events))
+	for i := range events {
+		views = append(views, newAuditEventView(&events[i]))
+	}
+	response := map[string]interface{}{
+		"events": views,
+	}
+	if len(events) == limit {
+		response["next_before_id"] = events[len(events)-1].ID
+	}
+	json.NewEncoder(w).Encode(response)
+}
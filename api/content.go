@@ -2,37 +2,23 @@ package api
 
 import (
 	"encoding/json"
-	"fmt"
+	"log"
 	"net/http"
-	"strconv"
 
-	"github.com/gorilla/mux"
 	"github.com/roGal1k/golang-beginner/assets/model"
 )
 
 func (a *API) createContentHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	vars := mux.Vars(r)
-	projectName, ok := vars["projectname"]
-	if !ok {
-		http.Error(w, "Project name is required"+string(projectName), http.StatusBadRequest)
-		return
-	}
-	sectionName, ok := vars["sectionname"]
-	if !ok {
-		http.Error(w, "Section name is required"+string(sectionName), http.StatusBadRequest)
-		return
-	}
-
-	idSection := r.Header.Get("Id")
-	if idSection == "" {
-		http.Error(w, "id section is not found", http.StatusUnauthorized)
+	_, section, err := a.requestSection(r, model.ProjectRoleEditor)
+	if err != nil {
+		writeResolveError(w, err, "Section not found")
 		return
 	}
 
 	var request model.Content
-	err := json.NewDecoder(r.Body).Decode(&request)
+	err = json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -43,95 +29,89 @@ func (a *API) createContentHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	num, err := strconv.ParseUint(idSection, 10, 64)
-	if err != nil {
-		fmt.Println("Ошибка при конвертации:", err)
-		return
+	// Содержимое всегда создаётся в разделе из пути, а не из тела запроса
+	content := model.Content{
+		SectionID: section.ID,
+		Type:      request.Type,
+		Data:      request.Data,
 	}
 
-	request.SectionID = uint(num)
-
-	// Сохранение секции в базе данных
-	result := a.DB.Create(&request)
+	// Сохранение содержимого в базе данных
+	result := a.DB.Create(&content)
 	if result.Error != nil {
-		http.Error(w, result.Error.Error(), http.StatusInternalServerError)
+		log.Printf("content create: %v", result.Error)
+		http.Error(w, "Failed to create content", http.StatusInternalServerError)
 		return
 	}
 
+	a.audit(r, auditEntry{Action: auditContentCreated, TargetType: auditTargetContent, TargetID: content.ID, After: content})
+
 	// Ответ пользователю
 	w.WriteHeader(http.StatusCreated)
-	response := map[string]string{
-		"message": "Content created successfully",
-	}
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(content)
 }
 
 func (a *API) getContentHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	// Извлечение значений из URL-параметров
-	vars := mux.Vars(r)
-	projectName, ok := vars["projectname"]
-	if !ok {
-		http.Error(w, "Project name is required"+string(projectName), http.StatusBadRequest)
-		return
-	}
-	sectionName, ok := vars["sectionname"]
-	if !ok {
-		http.Error(w, "Section name is required"+string(sectionName), http.StatusBadRequest)
+	_, content, err := a.requestContent(r, model.ProjectRoleViewer)
+	if err != nil {
+		writeResolveError(w, err, "Content not found")
 		return
 	}
 
-	idContent := r.Header.Get("Id")
-	if idContent == "" {
-		http.Error(w, "id project is not found", http.StatusUnauthorized)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(content)
+}
+
+func (a *API) getContentsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	_, section, err := a.requestSection(r, model.ProjectRoleViewer)
+	if err != nil {
+		writeResolveError(w, err, "Section not found")
 		return
 	}
 
-	var Content []model.Section
-	err := a.DB.Where("content_id = ?", idContent).Find(&Content).Error
+	var contents []model.Content
+	err = a.DB.Where("section_id = ?", section.ID).Find(&contents).Error
 	if err != nil {
-		http.Error(w, "Failed to fetch projects", http.StatusInternalServerError)
+		http.Error(w, "Failed to fetch contents", http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(Content)
 	w.WriteHeader(http.StatusOK)
-}
-
-func (a *API) getContentsHandler(w http.ResponseWriter, r *http.Request) {
-
+	json.NewEncoder(w).Encode(contents)
 }
 
 func (a *API) updateContentHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	// Извлечение значений из URL-параметров
-	vars := mux.Vars(r)
-	projectName, ok := vars["projectname"]
-	if !ok {
-		http.Error(w, "Project name is required"+string(projectName), http.StatusBadRequest)
-		return
-	}
-	sectionName, ok := vars["sectionname"]
-	if !ok {
-		http.Error(w, "Section name is required"+string(sectionName), http.StatusBadRequest)
+	_, content, err := a.requestContent(r, model.ProjectRoleEditor)
+	if err != nil {
+		writeResolveError(w, err, "Content not found")
 		return
 	}
 
-	idContent := r.Header.Get("Id")
-	if idContent == "" {
-		http.Error(w, "id project is not found", http.StatusUnauthorized)
+	var request model.Content
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	var Content []model.Section
-	err := a.DB.Where("content_id = ?", idContent).Find(&Content).Error
-	if err != nil {
-		http.Error(w, "Failed to fetch projects", http.StatusInternalServerError)
+	// Обновляются только тип и данные: перенос в другой раздел запрещён
+	before := *content
+	result := a.DB.Model(content).Select("Type", "Data").Updates(&request)
+	if result.Error != nil {
+		http.Error(w, "Failed to update content", http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(Content)
+	after := before
+	after.Type, after.Data = request.Type, request.Data
+	a.audit(r, auditEntry{Action: auditContentUpdated, TargetType: auditTargetContent, TargetID: content.ID, Before: before, After: after})
+
 	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(content)
 }
@@ -0,0 +1,236 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+)
+
+// Количество разделов и содержимого, копируемых за один запрос к базе
+const duplicateBatchSize = 500
+
+// Копия проекта со всеми разделами, содержимым, метками и файлами,
+// на которые ссылается содержимое. Копия принадлежит текущему пользователю
+// или организации: по умолчанию той же, что и исходный проект.
+func (a *API) duplicateProjectHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	source, err := a.requestProject(r, model.ProjectRoleViewer)
+	if err != nil {
+		writeResolveError(w, err, "Project not found")
+		return
+	}
+
+	var request struct {
+		Name         string `json:"name"`
+		Organization string `json:"organization"`
+	}
+	// Тело запроса необязательно
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user := currentUser(r)
+	project := model.Project{
+		UserID:     user.ID,
+		Name:       strings.TrimSpace(request.Name),
+		Visibility: model.VisibilityPrivate,
+	}
+	if project.Name == "" {
+		project.Name = source.Name + " (copy)"
+	}
+
+	// Организация копии: указанная в запросе или организация исходного проекта
+	var org *model.Organization
+	var role string
+	if request.Organization != "" {
+		org, role, err = a.resolveOrganization(user, request.Organization, model.OrgRoleMember)
+	} else if source.OrganizationID != nil {
+		org = &model.Organization{}
+		err = a.DB.First(org, *source.OrganizationID).Error
+		if err == nil {
+			role, err = a.organizationRole(user.ID, org.ID)
+		}
+	}
+	if err != nil {
+		writeResolveError(w, err, "Organization not found")
+		return
+	}
+	if org != nil {
+		if !canCreateInOrganization(org, role) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		project.OrganizationID = &org.ID
+		project.Visibility = org.DefaultVisibility
+	}
+
+	// Файлы копируются до транзакции: хранилище в ней не участвует
+	keys, copied, err := a.copyProjectMedia(r.Context(), user, source)
+	if err != nil {
+		log.Printf("project duplicate: %v", err)
+		http.Error(w, "Failed to copy files", http.StatusInternalServerError)
+		return
+	}
+
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		project.Slug, err = freeSlug(projectSlugScope(tx, &project), project.Name, model.SlugKindProject)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(&project).Error; err != nil {
+			return err
+		}
+		if project.OrganizationID != nil {
+			err := tx.Create(&model.ProjectMember{ProjectID: project.ID, UserID: user.ID, Role: model.ProjectRoleOwner}).Error
+			if err != nil {
+				return err
+			}
+		}
+		if len(copied) > 0 {
+			if err := tx.Create(&copied).Error; err != nil {
+				return err
+			}
+		}
+
+		tags, err := a.projectTags([]uint{source.ID})
+		if err != nil {
+			return err
+		}
+		project.Tags = tags[source.ID]
+		if err := replaceProjectTags(tx, project.ID, project.Tags); err != nil {
+			return err
+		}
+
+		return copySections(tx, source.ID, project.ID, keys)
+	})
+	if err != nil {
+		for _, media := range copied {
+			a.Storage.Delete(r.Context(), media.Key)
+		}
+		http.Error(w, "Failed to duplicate project", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditProjectDuplicated, TargetType: auditTargetProject, TargetID: project.ID, After: project,
+		Metadata: map[string]interface{}{"source_id": source.ID}})
+
+	a.writeProject(w, r, &project, http.StatusCreated)
+}
+
+// Копирование в хранилище файлов, на которые ссылается содержимое проекта.
+// Возвращает замену ключей для текста содержимого и записи о новых файлах.
+func (a *API) copyProjectMedia(ctx context.Context, user *model.User, source *model.Project) (*strings.Replacer, []model.Media, error) {
+	files, err := a.referencedMedia(source.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	copied := make([]model.Media, 0, len(files))
+	fail := func(err error) (*strings.Replacer, []model.Media, error) {
+		for _, media := range copied {
+			a.Storage.Delete(ctx, media.Key)
+		}
+		return nil, nil, err
+	}
+	for _, media := range files {
+		key, err := newMediaKey(user.ID, media.Filename)
+		if err != nil {
+			return fail(err)
+		}
+		if err := a.Storage.Copy(ctx, media.Key, key); err != nil {
+			return fail(err)
+		}
+		copied = append(copied, model.Media{
+			UserID:      user.ID,
+			Key:         key,
+			Filename:    media.Filename,
+			ContentType: media.ContentType,
+			Size:        media.Size,
+		})
+	}
+
+	replacements := make(map[string]string, len(files))
+	for i := range files {
+		replacements[files[i].Key] = copied[i].Key
+	}
+	return keyReplacer(replacements), copied, nil
+}
+
+// Файлы, на которые ссылается содержимое проекта
+func (a *API) referencedMedia(projectID uint) ([]model.Media, error) {
+	var files []model.Media
+	err := a.DB.Where("EXISTS (?)", a.DB.Model(&model.Content{}).Select("1").
+		Joins("JOIN sections ON sections.id = contents.section_id AND sections.deleted_at IS NULL").
+		Where("sections.project_id = ? AND strpos(contents.data, media.key) > 0", projectID)).
+		Order("id").Find(&files).Error
+	return files, err
+}
+
+// Замена ссылок на файлы в тексте содержимого. Длинные строки заменяются
+// первыми, чтобы ключ-префикс не задел чужую ссылку.
+func keyReplacer(replacements map[string]string) *strings.Replacer {
+	old := make([]string, 0, len(replacements))
+	for key := range replacements {
+		old = append(old, key)
+	}
+	sort.Slice(old, func(i, j int) bool {
+		if len(old[i]) != len(old[j]) {
+			return len(old[i]) > len(old[j])
+		}
+		return old[i] < old[j]
+	})
+
+	pairs := make([]string, 0, 2*len(old))
+	for _, key := range old {
+		pairs = append(pairs, key, replacements[key])
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// Копирование разделов и их содержимого порциями, не загружая проект
+// в память целиком. Адреса разделов сохраняются: в новом проекте
+// они так же уникальны.
+func copySections(tx *gorm.DB, sourceID, projectID uint, keys *strings.Replacer) error {
+	var sections []model.Section
+	return tx.Where("project_id = ?", sourceID).FindInBatches(&sections, duplicateBatchSize, func(_ *gorm.DB, _ int) error {
+		copies := make([]model.Section, 0, len(sections))
+		oldIDs := make([]uint, 0, len(sections))
+		for _, section := range sections {
+			copies = append(copies, model.Section{ProjectID: projectID, Title: section.Title, Slug: section.Slug})
+			oldIDs = append(oldIDs, section.ID)
+		}
+		if err := tx.Create(&copies).Error; err != nil {
+			return err
+		}
+
+		sectionIDs := make(map[uint]uint, len(copies))
+		for i := range copies {
+			sectionIDs[oldIDs[i]] = copies[i].ID
+		}
+
+		var contents []model.Content
+		return tx.Where("section_id IN ?", oldIDs).FindInBatches(&contents, duplicateBatchSize, func(_ *gorm.DB, _ int) error {
+			copies := make([]model.Content, 0, len(contents))
+			for _, content := range contents {
+				copies = append(copies, model.Content{
+					SectionID: sectionIDs[content.SectionID],
+					Type:      content.Type,
+					Data:      keys.Replace(content.Data),
+				})
+			}
+			return tx.Create(&copies).Error
+		}).Error
+	}).Error
+}
@@ -0,0 +1,269 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/roGal1k/golang-beginner/internal/config"
+)
+
+// База в памяти для тестов без PostgreSQL. Запросы записываются;
+// SELECT возвращает строки таблицы, подходящие под условия вида
+// column = $n и column IN (SELECT ...) во WHERE запроса. Прочие
+// условия, включая EXISTS, не проверяются, поэтому база возвращает
+// не меньше строк, чем PostgreSQL: если нужное ограничение пропадёт
+// из запроса, чужая строка окажется в результате.
+type fakeDB struct {
+	mu      sync.Mutex
+	tables  map[string][]fakeRow
+	queries []fakeQuery
+	nextID  int64
+}
+
+type fakeRow map[string]driver.Value
+
+type fakeQuery struct {
+	SQL  string
+	Args []driver.Value
+}
+
+// API поверх базы в памяти
+func newFakeAPI(t *testing.T) (*API, *fakeDB) {
+	t.Helper()
+
+	fake := &fakeDB{tables: make(map[string][]fakeRow), nextID: 1000}
+	database, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(fake)}),
+		&gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &API{DB: database, Config: config.Default()}, fake
+}
+
+// Добавление строки в таблицу
+func (f *fakeDB) insert(table string, row fakeRow) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tables[table] = append(f.tables[table], row)
+}
+
+// Выполненные запросы
+func (f *fakeDB) log() []fakeQuery {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeQuery(nil), f.queries...)
+}
+
+var (
+	fakeTablePattern     = regexp.MustCompile(`FROM "(\w+)"`)
+	fakeConditionPattern = regexp.MustCompile(`(?:"?(\w+)"?\.)?"?(\w+)"? = \$(\d+)`)
+)
+
+func (f *fakeDB) query(query string, args []driver.Value) (driver.Rows, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queries = append(f.queries, fakeQuery{SQL: query, Args: args})
+
+	switch {
+	case strings.HasPrefix(query, "INSERT"):
+		// RETURNING "id": новый идентификатор для каждой вставленной строки
+		rows := &fakeRows{columns: []string{"id"}}
+		for i := strings.Count(query, "),(") + 1; i > 0; i-- {
+			f.nextID++
+			rows.values = append(rows.values, []driver.Value{f.nextID})
+		}
+		return rows, nil
+	case strings.HasPrefix(query, "SELECT count(*)"):
+		return &fakeRows{columns: []string{"count"}, values: [][]driver.Value{{int64(len(f.match(query, args)))}}}, nil
+	case strings.HasPrefix(query, "SELECT"):
+		rows := &fakeRows{}
+		for _, row := range f.match(query, args) {
+			if rows.columns == nil {
+				for column := range row {
+					rows.columns = append(rows.columns, column)
+				}
+				sort.Strings(rows.columns)
+			}
+			values := make([]driver.Value, 0, len(rows.columns))
+			for _, column := range rows.columns {
+				values = append(values, row[column])
+			}
+			rows.values = append(rows.values, values)
+		}
+		if rows.columns == nil {
+			rows.columns = []string{"id"}
+		}
+		return rows, nil
+	}
+	return &fakeRows{columns: []string{"id"}}, nil
+}
+
+// Строки таблицы запроса, подходящие под его условия-равенства
+// и условия column IN (SELECT ...)
+func (f *fakeDB) match(query string, args []driver.Value) []fakeRow {
+	table := fakeTablePattern.FindStringSubmatch(query)
+	if table == nil {
+		return nil
+	}
+	where := ""
+	var ins []fakeIn
+	if i := strings.Index(query, " WHERE "); i >= 0 {
+		where, ins = f.subqueries(query[i:], args)
+		for _, end := range []string{" ORDER BY ", " GROUP BY ", " LIMIT "} {
+			if j := strings.Index(where, end); j >= 0 {
+				where = where[:j]
+			}
+		}
+	}
+	own := func(qualifier string) bool { return qualifier == "" || qualifier == table[1] }
+
+	var result []fakeRow
+rows:
+	for _, row := range f.tables[table[1]] {
+		for _, condition := range fakeConditionPattern.FindAllStringSubmatch(where, -1) {
+			value, known := row[condition[2]]
+			n, _ := strconv.Atoi(condition[3])
+			if !own(condition[1]) || !known || n < 1 || n > len(args) {
+				continue
+			}
+			if fmt.Sprint(value) != fmt.Sprint(args[n-1]) {
+				continue rows
+			}
+		}
+		for _, in := range ins {
+			value, known := row[in.column]
+			if own(in.table) && known && !in.values[fmt.Sprint(value)] {
+				continue rows
+			}
+		}
+		result = append(result, row)
+	}
+	return result
+}
+
+// Условие column IN (SELECT ...) с вычисленными значениями подзапроса
+type fakeIn struct {
+	table, column string
+	values        map[string]bool
+}
+
+var (
+	fakeInPattern     = regexp.MustCompile(`(?:"?(\w+)"?\.)?"?(\w+)"? IN $`)
+	fakeSelectPattern = regexp.MustCompile(`^\(SELECT (?:"?\w+"?\.)?"?(\w+)"?`)
+)
+
+// Запрос без вложенных (SELECT ...). Подзапросы в условиях IN
+// выполняются, остальные (EXISTS) пропускаются.
+func (f *fakeDB) subqueries(query string, args []driver.Value) (string, []fakeIn) {
+	var b strings.Builder
+	var ins []fakeIn
+	for {
+		start := strings.Index(query, "(SELECT")
+		if start < 0 {
+			b.WriteString(query)
+			return b.String(), ins
+		}
+		b.WriteString(query[:start])
+		depth := 0
+		end := start
+		for ; end < len(query); end++ {
+			if query[end] == '(' {
+				depth++
+			} else if query[end] == ')' {
+				depth--
+				if depth == 0 {
+					break
+				}
+			}
+		}
+		if end >= len(query) {
+			return b.String(), ins
+		}
+
+		subquery := query[start : end+1]
+		if in := fakeInPattern.FindStringSubmatch(b.String()); in != nil {
+			condition := fakeIn{table: in[1], column: in[2], values: make(map[string]bool)}
+			if selected := fakeSelectPattern.FindStringSubmatch(subquery); selected != nil {
+				for _, row := range f.match(subquery[1:len(subquery)-1], args) {
+					condition.values[fmt.Sprint(row[selected[1]])] = true
+				}
+			}
+			ins = append(ins, condition)
+		}
+		query = query[end+1:]
+	}
+}
+
+// Реализация database/sql/driver
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("fake driver: use a connector")
+}
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fake driver: prepared statements are not supported")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.db.query(query, namedValues(args))
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.queries = append(c.db.queries, fakeQuery{SQL: query, Args: namedValues(args)})
+	return driver.RowsAffected(1), nil
+}
+
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, 0, len(args))
+	for _, arg := range args {
+		values = append(values, arg.Value)
+	}
+	return values
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
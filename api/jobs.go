@@ -0,0 +1,89 @@
+package api
+
+import (
+	"log"
+	"time"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+)
+
+// Интервал очистки устаревших записей
+const cleanupInterval = time.Hour
+
+// Запуск фоновых задач обслуживания
+func (a *API) startJobs() {
+	go runPeriodically("purge revoked tokens", cleanupInterval, a.purgeRevokedTokens)
+	go runPeriodically("purge expired refresh tokens", cleanupInterval, a.purgeRefreshTokens)
+	go runPeriodically("purge expired user tokens", cleanupInterval, a.purgeUserTokens)
+	go runPeriodically("purge expired login challenges", cleanupInterval, a.purgeLoginChallenges)
+	go runPeriodically("purge stale login throttles", cleanupInterval, a.purgeLoginThrottles)
+	go runPeriodically("purge expired oidc states", cleanupInterval, a.purgeOIDCStates)
+	go runPeriodically("purge stale sessions", cleanupInterval, a.purgeSessions)
+	go runPeriodically("purge deleted accounts", cleanupInterval, a.purgeDeletedAccounts)
+	go runPeriodically("purge expired invitations", cleanupInterval, a.purgeInvitations)
+	go runPeriodically("purge trash", cleanupInterval, a.purgeTrash)
+	if interval := time.Duration(a.Config.Snapshots.Interval); interval > 0 {
+		go runPeriodically("snapshot projects", interval, a.snapshotProjects)
+	}
+}
+
+// Периодический запуск задачи; ошибки только логируются
+func runPeriodically(name string, interval time.Duration, job func() error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job(); err != nil {
+			log.Printf("job %q failed: %v", name, err)
+		}
+		<-ticker.C
+	}
+}
+
+// Удаление записей об отозванных токенах, срок действия которых уже истёк
+func (a *API) purgeRevokedTokens() error {
+	return a.DB.Unscoped().Where("expires_at < ?", time.Now()).Delete(&model.RevokedToken{}).Error
+}
+
+// Удаление истёкших refresh-токенов
+func (a *API) purgeRefreshTokens() error {
+	return a.DB.Unscoped().Where("expires_at < ?", time.Now()).Delete(&model.RefreshToken{}).Error
+}
+
+// Удаление истёкших одноразовых токенов
+func (a *API) purgeUserTokens() error {
+	return a.DB.Unscoped().Where("expires_at < ?", time.Now()).Delete(&model.UserToken{}).Error
+}
+
+// Удаление истёкших challenge незавершённых входов
+func (a *API) purgeLoginChallenges() error {
+	return a.DB.Unscoped().Where("expires_at < ?", time.Now()).Delete(&model.LoginChallenge{}).Error
+}
+
+// Удаление счётчиков без активной блокировки и без недавних ошибок
+func (a *API) purgeLoginThrottles() error {
+	since := time.Now().Add(-time.Duration(a.Config.Auth.Throttle.Window))
+	return a.DB.Unscoped().
+		Where("last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)", since, time.Now()).
+		Delete(&model.LoginThrottle{}).Error
+}
+
+// Удаление незавершённых входов через OpenID Connect
+func (a *API) purgeOIDCStates() error {
+	return a.DB.Unscoped().Where("expires_at < ?", time.Now()).Delete(&model.OIDCLoginState{}).Error
+}
+
+// Удаление сессий, отозванных или неактивных дольше срока действия refresh-токена
+func (a *API) purgeSessions() error {
+	cutoff := time.Now().Add(-a.refreshTokenTTL())
+	return a.DB.Unscoped().
+		Where("revoked_at < ? OR last_seen_at < ?", cutoff, cutoff).
+		Delete(&model.Session{}).Error
+}
+
+// Удаление приглашений, оставшихся без ответа после истечения срока
+func (a *API) purgeInvitations() error {
+	return a.DB.Unscoped().
+		Where("status = ? AND expires_at < ?", model.InvitationPending, time.Now()).
+		Delete(&model.ProjectInvitation{}).Error
+}
@@ -0,0 +1,292 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"github.com/roGal1k/golang-beginner/internal/config"
+)
+
+// Метод подписи EdDSA (Ed25519), которого нет в jwt-go
+type signingMethodEd25519 struct{}
+
+var signingMethodEdDSA = &signingMethodEd25519{}
+
+func init() {
+	jwt.RegisterSigningMethod(signingMethodEdDSA.Alg(), func() jwt.SigningMethod {
+		return signingMethodEdDSA
+	})
+}
+
+func (m *signingMethodEd25519) Alg() string {
+	return "EdDSA"
+}
+
+func (m *signingMethodEd25519) Verify(signingString, signature string, key interface{}) error {
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(publicKey, []byte(signingString), sig) {
+		return jwt.ErrSignatureInvalid
+	}
+	return nil
+}
+
+func (m *signingMethodEd25519) Sign(signingString string, key interface{}) (string, error) {
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+	return jwt.EncodeSegment(ed25519.Sign(privateKey, []byte(signingString))), nil
+}
+
+// Ключ подписи токенов
+type signingKey struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   interface{} // nil, если ключ только для проверки
+	verifyKey interface{}
+}
+
+// Набор ключей: активный ключ подписывает новые токены,
+// остальные принимаются при проверке во время ротации
+type KeySet struct {
+	keys   map[string]*signingKey
+	active *signingKey
+}
+
+// Загрузка ключей из настроек
+func LoadKeySet(cfg config.AuthConfig) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*signingKey)}
+
+	for _, kc := range cfg.Keys {
+		if kc.ID == "" {
+			return nil, errors.New("signing key without kid")
+		}
+		if _, exists := ks.keys[kc.ID]; exists {
+			return nil, fmt.Errorf("duplicate signing key %q", kc.ID)
+		}
+
+		key, err := loadSigningKey(kc)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q: %w", kc.ID, err)
+		}
+		ks.keys[kc.ID] = key
+	}
+
+	if len(ks.keys) == 0 {
+		// Без настроенных ключей используется временный ключ:
+		// выданные токены перестанут приниматься после перезапуска
+		log.Println("No signing keys configured, using an ephemeral EdDSA key")
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		ks.active = &signingKey{
+			id:        "ephemeral",
+			method:    signingMethodEdDSA,
+			signKey:   privateKey,
+			verifyKey: privateKey.Public(),
+		}
+		ks.keys[ks.active.id] = ks.active
+		return ks, nil
+	}
+
+	active, ok := ks.keys[cfg.ActiveKey]
+	if !ok {
+		return nil, fmt.Errorf("active signing key %q not found", cfg.ActiveKey)
+	}
+	if active.signKey == nil {
+		return nil, fmt.Errorf("active signing key %q has no private key", cfg.ActiveKey)
+	}
+	ks.active = active
+
+	return ks, nil
+}
+
+func loadSigningKey(kc config.KeyConfig) (*signingKey, error) {
+	key := &signingKey{id: kc.ID}
+
+	switch kc.Algorithm {
+	case "HS256":
+		secret := kc.Secret
+		if kc.SecretEnv != "" {
+			secret = os.Getenv(kc.SecretEnv)
+		}
+		if len(secret) < 32 {
+			return nil, errors.New("HS256 secret must be at least 32 bytes")
+		}
+		key.method = jwt.SigningMethodHS256
+		key.signKey = []byte(secret)
+		key.verifyKey = []byte(secret)
+
+	case "RS256":
+		key.method = jwt.SigningMethodRS256
+		if kc.PrivateKeyFile != "" {
+			data, err := os.ReadFile(kc.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			key.signKey = privateKey
+			key.verifyKey = &privateKey.PublicKey
+		} else if kc.PublicKeyFile != "" {
+			data, err := os.ReadFile(kc.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			publicKey, err := jwt.ParseRSAPublicKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			key.verifyKey = publicKey
+		} else {
+			return nil, errors.New("private_key_file or public_key_file is required")
+		}
+
+	case "EdDSA":
+		key.method = signingMethodEdDSA
+		if kc.PrivateKeyFile != "" {
+			der, err := readPEM(kc.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			parsed, err := x509.ParsePKCS8PrivateKey(der)
+			if err != nil {
+				return nil, err
+			}
+			privateKey, ok := parsed.(ed25519.PrivateKey)
+			if !ok {
+				return nil, errors.New("not an Ed25519 private key")
+			}
+			key.signKey = privateKey
+			key.verifyKey = privateKey.Public()
+		} else if kc.PublicKeyFile != "" {
+			der, err := readPEM(kc.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			parsed, err := x509.ParsePKIXPublicKey(der)
+			if err != nil {
+				return nil, err
+			}
+			publicKey, ok := parsed.(ed25519.PublicKey)
+			if !ok {
+				return nil, errors.New("not an Ed25519 public key")
+			}
+			key.verifyKey = publicKey
+		} else {
+			return nil, errors.New("private_key_file or public_key_file is required")
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", kc.Algorithm)
+	}
+
+	return key, nil
+}
+
+func readPEM(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM data")
+	}
+	return block.Bytes, nil
+}
+
+// Подпись токена активным ключом с указанием kid в заголовке
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.method, claims)
+	token.Header["kid"] = ks.active.id
+	return token.SignedString(ks.active.signKey)
+}
+
+// Выбор ключа проверки по kid; алгоритм токена обязан совпадать с алгоритмом ключа
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %q", token.Method.Alg())
+	}
+	return key.verifyKey, nil
+}
+
+// Открытый ключ в формате JWK
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// Открытые ключи набора; симметричные ключи не публикуются
+func (ks *KeySet) publicKeys() []jwk {
+	keys := []jwk{}
+	for _, key := range ks.keys {
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, jwk{
+				Kty: "RSA",
+				Kid: key.id,
+				Alg: key.method.Alg(),
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			keys = append(keys, jwk{
+				Kty: "OKP",
+				Kid: key.id,
+				Alg: key.method.Alg(),
+				Use: "sig",
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	return keys
+}
+
+// Публикация открытых ключей для проверки токенов другими сервисами
+func (a *API) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	response := map[string][]jwk{
+		"keys": a.Keys.publicKeys(),
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+)
+
+// Профиль текущего пользователя
+type profile struct {
+	ID              uint       `json:"id"`
+	Username        string     `json:"username"`
+	Role            string     `json:"role"`
+	Email           string     `json:"email"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	TwoFactor       bool       `json:"two_factor_enabled"`
+	// Момент окончательного удаления учётной записи, если оно запрошено
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+func newProfile(user *model.User) profile {
+	return profile{
+		ID:                  user.ID,
+		Username:            user.Username,
+		Role:                user.Role,
+		Email:               user.Email,
+		EmailVerifiedAt:     user.EmailVerifiedAt,
+		TwoFactor:           user.TOTPEnabledAt != nil,
+		DeletionScheduledAt: user.DeletionScheduledAt,
+		CreatedAt:           user.CreatedAt,
+	}
+}
+
+func (a *API) meHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(w).Encode(newProfile(currentUser(r)))
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+)
+
+// Максимальный размер загружаемого файла
+const maxUploadSize = 10 << 20
+
+// Допустимые типы загружаемых файлов
+var allowedMediaTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+var filenameDisallowed = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// Описание файла в ответах API
+type mediaView struct {
+	ID          uint      `json:"id"`
+	URL         string    `json:"url"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (a *API) newMediaView(media *model.Media) mediaView {
+	return mediaView{
+		ID:          media.ID,
+		URL:         a.Storage.URL(media.Key),
+		Filename:    media.Filename,
+		ContentType: media.ContentType,
+		Size:        media.Size,
+		CreatedAt:   media.CreatedAt,
+	}
+}
+
+// Новый ключ файла пользователя в хранилище
+func newMediaKey(userID uint, filename string) (string, error) {
+	suffix, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+	filename = strings.Trim(filenameDisallowed.ReplaceAllString(path.Base(filename), "_"), "._")
+	if filename == "" {
+		filename = "image"
+	}
+	return fmt.Sprintf("images/%d/%s_%s", userID, suffix[:12], filename), nil
+}
+
+// Загрузка изображения (multipart, поле file). В ответе - ссылка на файл.
+func (a *API) uploadMediaHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize+1<<20)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "File is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxUploadSize {
+		http.Error(w, "File is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Тип определяется по содержимому, а не по заголовку клиента
+	sniff := make([]byte, 512)
+	n, _ := file.Read(sniff)
+	contentType := http.DetectContentType(sniff[:n])
+	if !allowedMediaTypes[contentType] {
+		http.Error(w, "Unsupported file type", http.StatusUnsupportedMediaType)
+		return
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		http.Error(w, "Failed to read file", http.StatusInternalServerError)
+		return
+	}
+
+	user := currentUser(r)
+	key, err := newMediaKey(user.ID, header.Filename)
+	if err != nil {
+		http.Error(w, "Failed to upload file", http.StatusInternalServerError)
+		return
+	}
+
+	err = a.Storage.Put(r.Context(), key, file, contentType)
+	if err != nil {
+		log.Printf("media upload: %v", err)
+		http.Error(w, "Failed to upload file", http.StatusInternalServerError)
+		return
+	}
+
+	media := model.Media{
+		UserID:      user.ID,
+		Key:         key,
+		Filename:    header.Filename,
+		ContentType: contentType,
+		Size:        header.Size,
+	}
+	err = a.DB.Create(&media).Error
+	if err != nil {
+		a.Storage.Delete(r.Context(), key)
+		http.Error(w, "Failed to save file", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditMediaUploaded, TargetType: auditTargetMedia, TargetID: media.ID, After: media})
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(a.newMediaView(&media))
+}
+
+// Список загруженных файлов текущего пользователя
+func (a *API) getMediaHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var files []model.Media
+	err := a.DB.Where("user_id = ?", currentUser(r).ID).Order("id").Find(&files).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch files", http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]mediaView, 0, len(files))
+	for i := range files {
+		views = append(views, a.newMediaView(&files[i]))
+	}
+	json.NewEncoder(w).Encode(views)
+}
+
+// Удаление файла вместе с объектом в хранилище
+func (a *API) deleteMediaHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var media model.Media
+	err := a.DB.Where("id = ? AND user_id = ?", mux.Vars(r)["id"], currentUser(r).ID).First(&media).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	err = a.Storage.Delete(r.Context(), media.Key)
+	if err != nil {
+		log.Printf("media delete: %v", err)
+		http.Error(w, "Failed to delete file", http.StatusInternalServerError)
+		return
+	}
+
+	err = a.DB.Unscoped().Delete(&media).Error
+	if err != nil {
+		http.Error(w, "Failed to delete file", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditMediaDeleted, TargetType: auditTargetMedia, TargetID: media.ID, Before: media})
+
+	response := map[string]string{
+		"message": "File deleted",
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Раздача файлов локального хранилища без списков каталогов
+func serveUploads(dir string) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "" || strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,604 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+	"github.com/roGal1k/golang-beginner/internal/mail"
+)
+
+// Срок действия приглашения в проект
+const invitationTTL = 7 * 24 * time.Hour
+
+var (
+	errAlreadyMember     = errors.New("user is already a member of the project")
+	errInvitationPending = errors.New("user already has a pending invitation")
+	errInvalidInvitation = errors.New("invalid or expired invitation")
+)
+
+// Допустимая роль участника
+func isProjectRole(role string) bool {
+	return projectRoleRank(role) > 0
+}
+
+// Участник проекта в ответах API
+type memberView struct {
+	UserID   uint      `json:"user_id"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+	Since    time.Time `json:"since"`
+	Creator  bool      `json:"creator"` // Владельца личного проекта нельзя удалить или понизить
+}
+
+// Список участников проекта
+func (a *API) getMembersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	project, err := a.requestProject(r, model.ProjectRoleViewer)
+	if err != nil {
+		writeResolveError(w, err, "Project not found")
+		return
+	}
+
+	var members []memberView
+	err = a.DB.Table("project_members").
+		Select("project_members.user_id, users.username, project_members.role, project_members.created_at AS since").
+		Joins("JOIN users ON users.id = project_members.user_id").
+		Where("project_members.project_id = ? AND project_members.deleted_at IS NULL", project.ID).
+		Order("project_members.id").
+		Scan(&members).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch members", http.StatusInternalServerError)
+		return
+	}
+	if members == nil {
+		members = []memberView{}
+	}
+
+	// Проекты организации не имеют личного владельца: их владельцы -
+	// участники с ролью owner и администраторы организации
+	if project.OrganizationID != nil {
+		json.NewEncoder(w).Encode(members)
+		return
+	}
+
+	var creator model.User
+	err = a.DB.Unscoped().First(&creator, project.UserID).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch members", http.StatusInternalServerError)
+		return
+	}
+
+	views := append([]memberView{{
+		UserID:   creator.ID,
+		Username: creator.Username,
+		Role:     model.ProjectRoleOwner,
+		Since:    project.CreatedAt,
+		Creator:  true,
+	}}, members...)
+	json.NewEncoder(w).Encode(views)
+}
+
+// Участник проекта из пути запроса
+func (a *API) requestMember(w http.ResponseWriter, r *http.Request, project *model.Project) (*model.ProjectMember, bool) {
+	if project.OrganizationID == nil && mux.Vars(r)["userid"] == strconv.FormatUint(uint64(project.UserID), 10) {
+		http.Error(w, "The project creator cannot be changed or removed", http.StatusConflict)
+		return nil, false
+	}
+
+	var member model.ProjectMember
+	err := a.DB.Where("project_id = ? AND user_id = ?", project.ID, mux.Vars(r)["userid"]).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return &member, true
+}
+
+// Изменение роли участника (только владельцы)
+func (a *API) updateMemberHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		Role string `json:"role"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !isProjectRole(request.Role) {
+		http.Error(w, "Unknown role", http.StatusBadRequest)
+		return
+	}
+
+	project, err := a.requestProject(r, model.ProjectRoleOwner)
+	if err != nil {
+		writeResolveError(w, err, "Project not found")
+		return
+	}
+
+	member, ok := a.requestMember(w, r, project)
+	if !ok {
+		return
+	}
+
+	before := *member
+	err = a.DB.Model(member).Update("role", request.Role).Error
+	if err != nil {
+		http.Error(w, "Failed to update member", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{
+		Action:     auditMemberRoleChanged,
+		TargetType: auditTargetProject,
+		TargetID:   project.ID,
+		Before:     map[string]interface{}{"UserID": before.UserID, "Role": before.Role},
+		After:      map[string]interface{}{"UserID": before.UserID, "Role": request.Role},
+	})
+
+	response := map[string]string{
+		"message": "Member updated",
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Удаление участника. Владельцы удаляют любого участника,
+// остальные могут только покинуть проект сами.
+func (a *API) removeMemberHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	project, err := a.requestProject(r, model.ProjectRoleViewer)
+	if err != nil {
+		writeResolveError(w, err, "Project not found")
+		return
+	}
+
+	member, ok := a.requestMember(w, r, project)
+	if !ok {
+		return
+	}
+
+	user := currentUser(r)
+	if member.UserID != user.ID {
+		role, err := a.projectRole(user, project)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if role != model.ProjectRoleOwner {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	err = a.DB.Unscoped().Delete(member).Error
+	if err != nil {
+		http.Error(w, "Failed to remove member", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{
+		Action:     auditMemberRemoved,
+		TargetType: auditTargetProject,
+		TargetID:   project.ID,
+		Before:     map[string]interface{}{"UserID": member.UserID, "Role": member.Role},
+	})
+
+	response := map[string]string{
+		"message": "Member removed",
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Приглашение в ответах API
+type invitationView struct {
+	ID          uint      `json:"id"`
+	ProjectID   uint      `json:"project_id"`
+	ProjectName string    `json:"project_name"`
+	InviterName string    `json:"inviter"`
+	InviteeID   *uint     `json:"invitee_id,omitempty"`
+	Email       string    `json:"email,omitempty"`
+	Role        string    `json:"role"`
+	Status      string    `json:"status"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Запрос приглашений вместе с названием проекта и именем пригласившего
+func (a *API) invitationViews() *gorm.DB {
+	return a.DB.Table("project_invitations").
+		Select("project_invitations.id, project_invitations.project_id, projects.name AS project_name, " +
+			"users.username AS inviter_name, project_invitations.invitee_id, project_invitations.email, " +
+			"project_invitations.role, project_invitations.status, project_invitations.expires_at, " +
+			"project_invitations.created_at").
+		Joins("JOIN projects ON projects.id = project_invitations.project_id AND projects.deleted_at IS NULL").
+		Joins("JOIN users ON users.id = project_invitations.inviter_id").
+		Where("project_invitations.deleted_at IS NULL")
+}
+
+// Приглашение пользователя в проект по имени или email (только владельцы)
+func (a *API) createInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		Username string `json:"username"`
+		Email    string `json:"email"`
+		Role     string `json:"role"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if (request.Username == "") == (request.Email == "") {
+		http.Error(w, "Either username or email is required", http.StatusBadRequest)
+		return
+	}
+	if !isProjectRole(request.Role) {
+		http.Error(w, "Unknown role", http.StatusBadRequest)
+		return
+	}
+
+	project, err := a.requestProject(r, model.ProjectRoleOwner)
+	if err != nil {
+		writeResolveError(w, err, "Project not found")
+		return
+	}
+
+	// Приглашаемый пользователь, если у него есть учётная запись
+	var invitee model.User
+	query := a.DB.Where("username = ?", request.Username)
+	if request.Email != "" {
+		query = a.DB.Where("email = ? AND email_verified_at IS NOT NULL", request.Email)
+	}
+	err = query.First(&invitee).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if err != nil && request.Username != "" {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	invitation := model.ProjectInvitation{
+		ProjectID: project.ID,
+		InviterID: currentUser(r).ID,
+		Email:     request.Email,
+		Role:      request.Role,
+		Status:    model.InvitationPending,
+		ExpiresAt: time.Now().Add(invitationTTL),
+	}
+	if invitee.ID != 0 {
+		invitation.InviteeID = &invitee.ID
+		if invitation.Email == "" {
+			invitation.Email = invitee.Email
+		}
+	}
+
+	token, err := newOpaqueToken()
+	if err != nil {
+		http.Error(w, "Failed to create invitation", http.StatusInternalServerError)
+		return
+	}
+	invitation.TokenHash = hashToken(token)
+
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		if invitee.ID != 0 {
+			role, err := a.projectRole(&invitee, project)
+			if err != nil {
+				return err
+			}
+			if role != "" {
+				return errAlreadyMember
+			}
+		}
+
+		var pending int64
+		query := tx.Model(&model.ProjectInvitation{}).
+			Where("project_id = ? AND status = ? AND expires_at > ?", project.ID, model.InvitationPending, time.Now())
+		if invitation.InviteeID != nil {
+			query = query.Where("invitee_id = ?", *invitation.InviteeID)
+		} else {
+			query = query.Where("email = ?", invitation.Email)
+		}
+		if err := query.Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending > 0 {
+			return errInvitationPending
+		}
+
+		return tx.Create(&invitation).Error
+	})
+	if errors.Is(err, errAlreadyMember) || errors.Is(err, errInvitationPending) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to create invitation", http.StatusInternalServerError)
+		return
+	}
+
+	if invitation.Email != "" {
+		link := fmt.Sprintf("%s/invitations/accept?token=%s", a.Config.PublicURL, url.QueryEscape(token))
+		a.sendMail(mail.Message{
+			To:      invitation.Email,
+			Subject: "Invitation to project " + project.Name,
+			Body: fmt.Sprintf("Hello!\n\n%s has invited you to the project %q as %s.\n"+
+				"To accept the invitation, sign in and open the link below:\n%s\n\n"+
+				"The invitation is valid for %s.\n", currentUser(r).Username, project.Name, invitation.Role, link, invitationTTL),
+		})
+	}
+
+	a.audit(r, auditEntry{
+		Action:     auditInvitationCreated,
+		TargetType: auditTargetProject,
+		TargetID:   project.ID,
+		Metadata: map[string]interface{}{
+			"invitation_id": invitation.ID,
+			"invitee_id":    invitation.InviteeID,
+			"email":         invitation.Email,
+			"role":          invitation.Role,
+		},
+	})
+
+	var view invitationView
+	err = a.invitationViews().Where("project_invitations.id = ?", invitation.ID).Scan(&view).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch invitation", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(view)
+}
+
+// Приглашения в проект (только владельцы)
+func (a *API) getInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	project, err := a.requestProject(r, model.ProjectRoleOwner)
+	if err != nil {
+		writeResolveError(w, err, "Project not found")
+		return
+	}
+
+	var views []invitationView
+	err = a.invitationViews().
+		Where("project_invitations.project_id = ?", project.ID).
+		Order("project_invitations.id DESC").
+		Scan(&views).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch invitations", http.StatusInternalServerError)
+		return
+	}
+	if views == nil {
+		views = []invitationView{}
+	}
+	json.NewEncoder(w).Encode(views)
+}
+
+// Отзыв приглашения (только владельцы)
+func (a *API) revokeInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	project, err := a.requestProject(r, model.ProjectRoleOwner)
+	if err != nil {
+		writeResolveError(w, err, "Project not found")
+		return
+	}
+
+	result := a.DB.Model(&model.ProjectInvitation{}).
+		Where("id = ? AND project_id = ? AND status = ?", mux.Vars(r)["id"], project.ID, model.InvitationPending).
+		Update("status", model.InvitationRevoked)
+	if result.Error != nil {
+		http.Error(w, "Failed to revoke invitation", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "Invitation not found", http.StatusNotFound)
+		return
+	}
+
+	a.audit(r, auditEntry{
+		Action:     auditInvitationRevoked,
+		TargetType: auditTargetProject,
+		TargetID:   project.ID,
+		Metadata:   map[string]interface{}{"invitation_id": mux.Vars(r)["id"]},
+	})
+
+	response := map[string]string{
+		"message": "Invitation revoked",
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Условие приглашений, адресованных пользователю: по учётной записи
+// или по подтверждённому email
+func invitedUser(query *gorm.DB, user *model.User) *gorm.DB {
+	if user.Email != "" && user.EmailVerifiedAt != nil {
+		return query.Where("(project_invitations.invitee_id = ? OR (project_invitations.invitee_id IS NULL AND project_invitations.email = ?))",
+			user.ID, user.Email)
+	}
+	return query.Where("project_invitations.invitee_id = ?", user.ID)
+}
+
+// Действующие приглашения текущего пользователя
+func (a *API) getMyInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var views []invitationView
+	query := a.invitationViews().
+		Where("project_invitations.status = ? AND project_invitations.expires_at > ?", model.InvitationPending, time.Now())
+	err := invitedUser(query, currentUser(r)).Order("project_invitations.id DESC").Scan(&views).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch invitations", http.StatusInternalServerError)
+		return
+	}
+	if views == nil {
+		views = []invitationView{}
+	}
+	json.NewEncoder(w).Encode(views)
+}
+
+// Принятие или отклонение приглашения. Приглашение одноразовое:
+// статус меняется условным обновлением.
+func (a *API) respondInvitation(tx *gorm.DB, invitation *model.ProjectInvitation, user *model.User, accept bool) error {
+	if invitation.Status != model.InvitationPending || time.Now().After(invitation.ExpiresAt) {
+		return errInvalidInvitation
+	}
+
+	status := model.InvitationDeclined
+	if accept {
+		status = model.InvitationAccepted
+	}
+	result := tx.Model(&model.ProjectInvitation{}).
+		Where("id = ? AND status = ?", invitation.ID, model.InvitationPending).
+		Updates(map[string]interface{}{"status": status, "invitee_id": user.ID})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errInvalidInvitation
+	}
+	if !accept {
+		return nil
+	}
+
+	// Блокировка проекта: одновременные принятия приглашений в один проект
+	// выполняются по очереди и видят уже созданных участников
+	var project model.Project
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", invitation.ProjectID).First(&project).Error
+	if err != nil {
+		return errInvalidInvitation
+	}
+	role, err := projectRoleTx(tx, user, &project)
+	if err != nil {
+		return err
+	}
+	if role != "" {
+		return errAlreadyMember
+	}
+
+	return tx.Create(&model.ProjectMember{
+		ProjectID: invitation.ProjectID,
+		UserID:    user.ID,
+		Role:      invitation.Role,
+	}).Error
+}
+
+// Ответ на приглашение из списка /me/invitations
+func (a *API) answerInvitationHandler(accept bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := currentUser(r)
+
+		var invitation model.ProjectInvitation
+		query := a.DB.Model(&model.ProjectInvitation{}).Where("project_invitations.id = ?", mux.Vars(r)["id"])
+		err := invitedUser(query, user).First(&invitation).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Invitation not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		err = a.DB.Transaction(func(tx *gorm.DB) error {
+			return a.respondInvitation(tx, &invitation, user, accept)
+		})
+		a.writeInvitationResult(w, r, &invitation, accept, err)
+	}
+}
+
+// Принятие приглашения по токену из письма
+func (a *API) acceptInvitationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		Token string `json:"token"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.Token == "" {
+		http.Error(w, "Token is required", http.StatusBadRequest)
+		return
+	}
+
+	var invitation model.ProjectInvitation
+	err = a.DB.Where("token_hash = ?", hashToken(request.Token)).First(&invitation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, errInvalidInvitation.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// Приглашение для конкретной учётной записи может принять только она
+	user := currentUser(r)
+	if invitation.InviteeID != nil && *invitation.InviteeID != user.ID {
+		http.Error(w, errInvalidInvitation.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		return a.respondInvitation(tx, &invitation, user, true)
+	})
+	a.writeInvitationResult(w, r, &invitation, true, err)
+}
+
+// Ответ на принятие или отклонение приглашения
+func (a *API) writeInvitationResult(w http.ResponseWriter, r *http.Request, invitation *model.ProjectInvitation, accept bool, err error) {
+	if errors.Is(err, errInvalidInvitation) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, errAlreadyMember) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to answer invitation", http.StatusInternalServerError)
+		return
+	}
+
+	action, message := auditInvitationDeclined, "Invitation declined"
+	if accept {
+		action, message = auditInvitationAccepted, "Invitation accepted"
+	}
+	a.audit(r, auditEntry{
+		Action:     action,
+		TargetType: auditTargetProject,
+		TargetID:   invitation.ProjectID,
+		Metadata:   map[string]interface{}{"invitation_id": invitation.ID, "role": invitation.Role},
+	})
+
+	response := map[string]string{
+		"message": message,
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,429 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+	"github.com/roGal1k/golang-beginner/internal/totp"
+)
+
+const (
+	loginChallengeTTL         = 5 * time.Minute // Время на ввод второго фактора
+	loginChallengeMaxAttempts = 5               // Попыток ввода кода на один вход
+	recoveryCodeCount         = 10
+)
+
+var errInvalidChallenge = errors.New("invalid or expired challenge")
+
+// Завершение входа после проверки пароля: при включённой двухфакторной
+// аутентификации вместо токенов выдаётся короткоживущий challenge
+func (a *API) completeLogin(w http.ResponseWriter, r *http.Request, user *model.User, method string) {
+	if !loginAllowed(w, user) {
+		return
+	}
+
+	if user.TOTPEnabledAt == nil {
+		tokens, err := a.createSession(user, a.requestDevice(r))
+		if err != nil {
+			http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+			return
+		}
+		a.audit(r, auditEntry{
+			ActorID:    &user.ID,
+			Action:     auditLogin,
+			TargetType: auditTargetUser,
+			TargetID:   user.ID,
+			Metadata:   map[string]interface{}{"method": method},
+		})
+		json.NewEncoder(w).Encode(tokens)
+		return
+	}
+
+	raw, err := newOpaqueToken()
+	if err != nil {
+		http.Error(w, "Failed to create challenge", http.StatusInternalServerError)
+		return
+	}
+
+	err = a.DB.Create(&model.LoginChallenge{
+		UserID:    user.ID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(loginChallengeTTL),
+	}).Error
+	if err != nil {
+		http.Error(w, "Failed to create challenge", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"mfa_required": true,
+		"challenge":    raw,
+		"expires_in":   int64(loginChallengeTTL / time.Second),
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Второй шаг входа: challenge и TOTP-код или резервный код
+func (a *API) loginSecondFactorHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		Challenge    string `json:"challenge"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if request.Challenge == "" || (request.Code == "" && request.RecoveryCode == "") {
+		http.Error(w, "Challenge and code are required", http.StatusBadRequest)
+		return
+	}
+
+	var challenge model.LoginChallenge
+	err = a.DB.Where("token_hash = ?", hashToken(request.Challenge)).First(&challenge).Error
+	if err != nil || challenge.UsedAt != nil || time.Now().After(challenge.ExpiresAt) ||
+		challenge.Attempts >= loginChallengeMaxAttempts {
+		http.Error(w, errInvalidChallenge.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var user model.User
+	err = a.DB.First(&user, challenge.UserID).Error
+	if err != nil {
+		http.Error(w, errInvalidChallenge.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	// Перебор кодов ограничивается тем же счётчиком, что и перебор паролей
+	userKey := userThrottleKey(user.Username)
+	retry, err := a.throttleRetryAfter(userKey)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if retry > 0 {
+		writeTooManyAttempts(w, retry)
+		return
+	}
+
+	ok, err := a.verifySecondFactor(&user, request.Code, request.RecoveryCode)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		err = a.DB.Model(&challenge).Update("attempts", gorm.Expr("attempts + 1")).Error
+		if err != nil {
+			log.Printf("login challenge %d: %v", challenge.ID, err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if !a.recordAttempt(w, userKey, a.Config.Auth.Throttle.UserFreeAttempts) {
+			return
+		}
+		a.audit(r, auditEntry{Action: auditSecondFactorFailed, TargetType: auditTargetUser, TargetID: user.ID})
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	// Challenge одноразовый
+	result := a.DB.Model(&model.LoginChallenge{}).
+		Where("id = ? AND used_at IS NULL", challenge.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, errInvalidChallenge.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	// Учётную запись могли заблокировать между шагами входа
+	if !loginAllowed(w, &user) {
+		return
+	}
+
+	tokens, err := a.createSession(&user, a.requestDevice(r))
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	secondFactor := "totp"
+	if request.RecoveryCode != "" {
+		secondFactor = "recovery_code"
+	}
+	a.audit(r, auditEntry{
+		ActorID:    &user.ID,
+		Action:     auditLogin,
+		TargetType: auditTargetUser,
+		TargetID:   user.ID,
+		Metadata:   map[string]interface{}{"second_factor": secondFactor},
+	})
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// Проверка второго фактора. Каждый TOTP-код и резервный код принимаются один раз.
+func (a *API) verifySecondFactor(user *model.User, code, recoveryCode string) (bool, error) {
+	if user.TOTPEnabledAt == nil {
+		return false, nil
+	}
+
+	if code != "" {
+		counter, ok := totp.Validate(user.TOTPSecret, code, time.Now(), 1)
+		if !ok {
+			return false, nil
+		}
+		result := a.DB.Model(&model.User{}).
+			Where("id = ? AND totp_last_counter < ?", user.ID, counter).
+			Update("totp_last_counter", counter)
+		return result.RowsAffected == 1, result.Error
+	}
+
+	result := a.DB.Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashToken(normalizeRecoveryCode(recoveryCode))).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+// Генерация нового набора резервных кодов взамен старых
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	err := tx.Unscoped().Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 6)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(base32.StdEncoding.EncodeToString(b))[:10]
+		code := raw[:5] + "-" + raw[5:]
+
+		err := tx.Create(&model.RecoveryCode{
+			UserID:   userID,
+			CodeHash: hashToken(normalizeRecoveryCode(code)),
+		}).Error
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// Приведение резервного кода к каноническому виду: регистр и дефисы не важны
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+// Начало подключения TOTP: выдача секрета и ссылки otpauth://
+func (a *API) totpSetupHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user := currentUser(r)
+	if user.TOTPEnabledAt != nil {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		http.Error(w, "Failed to generate secret", http.StatusInternalServerError)
+		return
+	}
+
+	err = a.DB.Model(user).Update("totp_pending_secret", secret).Error
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]string{
+		"secret":      secret,
+		"otpauth_uri": totp.URI(a.Config.Auth.TOTPIssuer, user.Username, secret),
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Подтверждение подключения TOTP первым кодом; в ответе резервные коды
+func (a *API) totpConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		Code string `json:"code"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user := currentUser(r)
+	if user.TOTPEnabledAt != nil {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+	if user.TOTPPendingSecret == "" {
+		http.Error(w, "Two-factor setup has not been started", http.StatusBadRequest)
+		return
+	}
+
+	counter, ok := totp.Validate(user.TOTPPendingSecret, request.Code, time.Now(), 1)
+	if !ok {
+		http.Error(w, "Invalid code", http.StatusBadRequest)
+		return
+	}
+
+	var codes []string
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_secret":         user.TOTPPendingSecret,
+			"totp_pending_secret": "",
+			"totp_enabled_at":     time.Now(),
+			"totp_last_counter":   counter,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Failed to enable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditTOTPEnabled, TargetType: auditTargetUser, TargetID: user.ID})
+
+	response := map[string]interface{}{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Выпуск нового набора резервных кодов (требует действующий второй фактор)
+func (a *API) recoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		Code string `json:"code"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user := currentUser(r)
+	ok, err := a.verifySecondFactor(user, request.Code, "")
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Invalid code", http.StatusBadRequest)
+		return
+	}
+
+	var codes []string
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Failed to generate recovery codes", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditRecoveryCodes, TargetType: auditTargetUser, TargetID: currentUser(r).ID})
+
+	response := map[string]interface{}{
+		"recovery_codes": codes,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Отключение TOTP: требуются пароль и второй фактор
+func (a *API) totpDisableHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user := currentUser(r)
+	if user.TOTPEnabledAt == nil {
+		http.Error(w, "Two-factor authentication is not enabled", http.StatusConflict)
+		return
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.Password))
+	if err != nil {
+		http.Error(w, "Invalid password", http.StatusForbidden)
+		return
+	}
+
+	ok, err := a.verifySecondFactor(user, request.Code, request.RecoveryCode)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Invalid code", http.StatusForbidden)
+		return
+	}
+
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_secret":         "",
+			"totp_pending_secret": "",
+			"totp_enabled_at":     nil,
+			"totp_last_counter":   0,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("user_id = ?", user.ID).Delete(&model.RecoveryCode{}).Error
+	})
+	if err != nil {
+		http.Error(w, "Failed to disable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditTOTPDisabled, TargetType: auditTargetUser, TargetID: currentUser(r).ID})
+
+	response := map[string]string{
+		"message": "Two-factor authentication disabled",
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/roGal1k/golang-beginner/internal/totp"
+)
+
+// Код TOTP принимается один раз: повтор того же кода и код более
+// раннего шага после использования отклоняются
+func TestVerifySecondFactorReplay(t *testing.T) {
+	a := newTestAPI(t)
+	user := createTestUser(t, a, "mfa")
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	user.TOTPSecret = secret
+	user.TOTPEnabledAt = &now
+	if err := a.DB.Save(user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := totp.Code(secret, totp.Counter(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, err := totp.Code(secret, totp.Counter(now)-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := a.verifySecondFactor(user, current, ""); err != nil || !ok {
+		t.Fatalf("first use of code: ok %v, err %v", ok, err)
+	}
+	if ok, err := a.verifySecondFactor(user, current, ""); err != nil || ok {
+		t.Fatalf("replayed code: ok %v, err %v", ok, err)
+	}
+	if previous != current {
+		if ok, err := a.verifySecondFactor(user, previous, ""); err != nil || ok {
+			t.Fatalf("code of an earlier step: ok %v, err %v", ok, err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+)
+
+type ctxKey int
+
+const authCtxKey ctxKey = iota
+
+// Данные аутентифицированного пользователя запроса
+type authInfo struct {
+	User   *model.User
+	Claims *Claims                    // nil при доступе по персональному токену
+	Token  *model.PersonalAccessToken // nil при доступе по токену сессии
+}
+
+// Есть ли у запроса право доступа. Токен сессии даёт все права,
+// персональный токен - только перечисленные при создании.
+func (auth *authInfo) hasScope(scope string) bool {
+	if auth.Token == nil {
+		return true
+	}
+	for _, granted := range strings.Fields(auth.Token.Scopes) {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware аутентификации: проверяет токен один раз на запрос
+// и помещает пользователя в контекст
+func (a *API) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, err := a.authorization(r)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), authCtxKey, auth)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Middleware маршрутов управления учётной записью: доступны только
+// по токену сессии, но не по персональному токену
+func (a *API) sessionOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if currentAuth(r).Claims == nil {
+			w.Header().Set("Content-Type", "application/json")
+			http.Error(w, "This endpoint requires an interactive session", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Middleware маршрутов, доступных только пользователям с одной из ролей
+func (a *API) requireRole(roles ...string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role := currentUser(r).Role
+			for _, allowed := range roles {
+				if role == allowed {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		})
+	}
+}
+
+// Проверка права доступа персонального токена для маршрута
+func (a *API) requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !currentAuth(r).hasScope(scope) {
+			w.Header().Set("Content-Type", "application/json")
+			http.Error(w, "Insufficient scope: "+scope, http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Данные аутентификации из контекста запроса
+func currentAuth(r *http.Request) *authInfo {
+	auth, _ := r.Context().Value(authCtxKey).(*authInfo)
+	return auth
+}
+
+// Пользователь, выполняющий запрос. Доступен только на защищённых маршрутах.
+func currentUser(r *http.Request) *model.User {
+	return currentAuth(r).User
+}
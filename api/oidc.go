@@ -0,0 +1,423 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+	"github.com/roGal1k/golang-beginner/internal/config"
+	"github.com/roGal1k/golang-beginner/internal/oidc"
+)
+
+// Время на прохождение входа у провайдера
+const oidcStateTTL = 10 * time.Minute
+
+var (
+	errUnknownProvider = errors.New("unknown identity provider")
+	errNoLinkedAccount = errors.New("no account is linked to this identity")
+	errIdentityInUse   = errors.New("identity is already linked to another account")
+)
+
+var oidcHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// Провайдер по имени; метаданные загружаются при первом обращении
+func (a *API) oidcProvider(ctx context.Context, name string) (*oidc.Provider, *config.OIDCProviderConfig, error) {
+	var cfg *config.OIDCProviderConfig
+	for i := range a.Config.OIDC {
+		if a.Config.OIDC[i].Name == name {
+			cfg = &a.Config.OIDC[i]
+			break
+		}
+	}
+	if cfg == nil {
+		return nil, nil, errUnknownProvider
+	}
+
+	a.oidcMu.Lock()
+	defer a.oidcMu.Unlock()
+
+	if provider, ok := a.oidcProviders[name]; ok {
+		return provider, cfg, nil
+	}
+
+	secret := cfg.ClientSecret
+	if cfg.ClientSecretEnv != "" {
+		secret = os.Getenv(cfg.ClientSecretEnv)
+	}
+
+	provider, err := oidc.Discover(ctx, oidcHTTPClient, oidc.Config{
+		Issuer:       cfg.Issuer,
+		ClientID:     cfg.ClientID,
+		ClientSecret: secret,
+		RedirectURL:  cfg.RedirectURL,
+		Scopes:       cfg.Scopes,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if a.oidcProviders == nil {
+		a.oidcProviders = make(map[string]*oidc.Provider)
+	}
+	a.oidcProviders[name] = provider
+	return provider, cfg, nil
+}
+
+// Сохранение state, nonce и verifier и формирование адреса входа у провайдера
+func (a *API) startOIDCLogin(ctx context.Context, providerName string, linkUserID *uint) (string, error) {
+	provider, _, err := a.oidcProvider(ctx, providerName)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+
+	err = a.DB.Create(&model.OIDCLoginState{
+		Provider:     providerName,
+		StateHash:    hashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		LinkUserID:   linkUserID,
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	}).Error
+	if err != nil {
+		return "", err
+	}
+
+	return provider.AuthCodeURL(state, nonce, verifier), nil
+}
+
+// Вход через провайдера: перенаправление на его страницу входа
+func (a *API) oidcLoginHandler(w http.ResponseWriter, r *http.Request) {
+	authURL, err := a.startOIDCLogin(r.Context(), mux.Vars(r)["provider"], nil)
+	if errors.Is(err, errUnknownProvider) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("oidc login: %v", err)
+		http.Error(w, "Identity provider is unavailable", http.StatusBadGateway)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Возврат от провайдера: проверка state, обмен кода и вход или привязка
+func (a *API) oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	providerName := mux.Vars(r)["provider"]
+	query := r.URL.Query()
+
+	if providerError := query.Get("error"); providerError != "" {
+		http.Error(w, "Identity provider error: "+providerError, http.StatusUnauthorized)
+		return
+	}
+
+	// state одноразовый: запись удаляется до обмена кода
+	var state model.OIDCLoginState
+	err := a.DB.Where("state_hash = ? AND provider = ?", hashToken(query.Get("state")), providerName).First(&state).Error
+	if err == nil {
+		err = a.DB.Unscoped().Delete(&state).Error
+	}
+	if err != nil || time.Now().After(state.ExpiresAt) {
+		http.Error(w, "Invalid or expired state", http.StatusBadRequest)
+		return
+	}
+
+	provider, providerCfg, err := a.oidcProvider(r.Context(), providerName)
+	if err != nil {
+		http.Error(w, "Identity provider is unavailable", http.StatusBadGateway)
+		return
+	}
+
+	identity, err := provider.Exchange(r.Context(), query.Get("code"), state.CodeVerifier, state.Nonce)
+	if err != nil {
+		log.Printf("oidc callback: %v", err)
+		http.Error(w, "Failed to verify identity", http.StatusUnauthorized)
+		return
+	}
+
+	// Привязка провайдера к уже вошедшему пользователю
+	if state.LinkUserID != nil {
+		err = a.linkIdentity(*state.LinkUserID, providerName, identity)
+		if errors.Is(err, errIdentityInUse) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to link identity", http.StatusInternalServerError)
+			return
+		}
+
+		a.audit(r, auditEntry{
+			ActorID:    state.LinkUserID,
+			Action:     auditIdentityLinked,
+			TargetType: auditTargetUser,
+			TargetID:   *state.LinkUserID,
+			Metadata:   map[string]interface{}{"provider": providerName, "email": identity.Email},
+		})
+
+		response := map[string]string{
+			"message": "Identity linked",
+		}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	user, err := a.resolveOIDCUser(providerCfg, identity)
+	if errors.Is(err, errNoLinkedAccount) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to sign in", http.StatusInternalServerError)
+		return
+	}
+
+	a.completeLogin(w, r, user, "oidc:"+providerName)
+}
+
+// Пользователь для внешней учётной записи: по привязке, по email
+// или новый (just-in-time), в зависимости от настроек провайдера
+func (a *API) resolveOIDCUser(cfg *config.OIDCProviderConfig, identity *oidc.Identity) (*model.User, error) {
+	var user model.User
+
+	var linked model.UserIdentity
+	err := a.DB.Where("provider = ? AND subject = ?", cfg.Name, identity.Subject).First(&linked).Error
+	if err == nil {
+		err = a.DB.First(&user, linked.UserID).Error
+		return &user, err
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Привязка по email допускается только для адресов, подтверждённых с обеих сторон
+	if cfg.LinkByEmail && identity.EmailVerified && identity.Email != "" {
+		err := a.DB.Where("email = ? AND email_verified_at IS NOT NULL", identity.Email).First(&user).Error
+		if err == nil {
+			if err := a.linkIdentity(user.ID, cfg.Name, identity); err != nil {
+				return nil, err
+			}
+			return &user, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	if !cfg.AllowSignup {
+		return nil, errNoLinkedAccount
+	}
+
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		username, err := uniqueUsername(tx, identity)
+		if err != nil {
+			return err
+		}
+
+		user = model.User{Username: username}
+		if identity.Email != "" {
+			var taken int64
+			if err := tx.Model(&model.User{}).Where("email = ?", identity.Email).Count(&taken).Error; err != nil {
+				return err
+			}
+			if taken == 0 {
+				user.Email = identity.Email
+				if identity.EmailVerified {
+					now := time.Now()
+					user.EmailVerifiedAt = &now
+				}
+			}
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&model.UserIdentity{
+			UserID:   user.ID,
+			Provider: cfg.Name,
+			Subject:  identity.Subject,
+			Email:    identity.Email,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Привязка внешней учётной записи к пользователю
+func (a *API) linkIdentity(userID uint, provider string, identity *oidc.Identity) error {
+	var existing model.UserIdentity
+	err := a.DB.Where("provider = ? AND subject = ?", provider, identity.Subject).First(&existing).Error
+	if err == nil {
+		if existing.UserID != userID {
+			return errIdentityInUse
+		}
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	return a.DB.Create(&model.UserIdentity{
+		UserID:   userID,
+		Provider: provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}).Error
+}
+
+var usernameDisallowed = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// Свободное имя пользователя на основе данных провайдера
+func uniqueUsername(tx *gorm.DB, identity *oidc.Identity) (string, error) {
+	base := identity.PreferredUsername
+	if base == "" && identity.Email != "" {
+		base = strings.SplitN(identity.Email, "@", 2)[0]
+	}
+	base = strings.Trim(usernameDisallowed.ReplaceAllString(base, "-"), "-")
+	if base == "" {
+		base = "user"
+	}
+
+	for i := 1; i <= 100; i++ {
+		candidate := base
+		if i > 1 {
+			candidate = fmt.Sprintf("%s-%d", base, i)
+		}
+
+		var taken int64
+		err := tx.Unscoped().Model(&model.User{}).Where("username = ?", candidate).Count(&taken).Error
+		if err != nil {
+			return "", err
+		}
+		if taken == 0 {
+			return candidate, nil
+		}
+	}
+
+	suffix, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+	return base + "-" + suffix[:8], nil
+}
+
+// Начало привязки провайдера к текущему пользователю
+func (a *API) linkIdentityHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID := currentUser(r).ID
+	authURL, err := a.startOIDCLogin(r.Context(), mux.Vars(r)["provider"], &userID)
+	if errors.Is(err, errUnknownProvider) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("oidc link: %v", err)
+		http.Error(w, "Identity provider is unavailable", http.StatusBadGateway)
+		return
+	}
+
+	response := map[string]string{
+		"authorization_url": authURL,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Список привязанных внешних учётных записей
+func (a *API) getIdentitiesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var identities []model.UserIdentity
+	err := a.DB.Where("user_id = ?", currentUser(r).ID).Find(&identities).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch identities", http.StatusInternalServerError)
+		return
+	}
+
+	type identityView struct {
+		ID        uint      `json:"id"`
+		Provider  string    `json:"provider"`
+		Email     string    `json:"email"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	views := make([]identityView, 0, len(identities))
+	for _, identity := range identities {
+		views = append(views, identityView{identity.ID, identity.Provider, identity.Email, identity.CreatedAt})
+	}
+	json.NewEncoder(w).Encode(views)
+}
+
+// Отвязка внешней учётной записи. Последний способ входа отвязать нельзя.
+func (a *API) unlinkIdentityHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user := currentUser(r)
+
+	var identity model.UserIdentity
+	err := a.DB.Where("id = ? AND user_id = ?", mux.Vars(r)["id"], user.ID).First(&identity).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Identity not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	var count int64
+	err = a.DB.Model(&model.UserIdentity{}).Where("user_id = ?", user.ID).Count(&count).Error
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if user.Password == "" && count <= 1 {
+		http.Error(w, "Set a password before unlinking the last identity", http.StatusConflict)
+		return
+	}
+
+	err = a.DB.Unscoped().Delete(&identity).Error
+	if err != nil {
+		http.Error(w, "Failed to unlink identity", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{
+		Action:     auditIdentityUnlinked,
+		TargetType: auditTargetIdentity,
+		TargetID:   identity.ID,
+		Metadata:   map[string]interface{}{"provider": identity.Provider},
+	})
+
+	response := map[string]string{
+		"message": "Identity unlinked",
+	}
+	json.NewEncoder(w).Encode(response)
+}
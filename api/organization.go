@@ -0,0 +1,615 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+)
+
+var (
+	errLastOrgOwner = errors.New("organization must have at least one owner")
+	errOrgNotEmpty  = errors.New("organization still owns projects")
+)
+
+// Порядок ролей организации: каждая следующая включает права предыдущих
+func orgRoleRank(role string) int {
+	switch role {
+	case model.OrgRoleMember:
+		return 1
+	case model.OrgRoleAdmin:
+		return 2
+	case model.OrgRoleOwner:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Допустимая видимость проекта
+func isVisibility(visibility string) bool {
+	return visibility == model.VisibilityPrivate || visibility == model.VisibilityOrganization
+}
+
+// Роль пользователя в организации; пустая строка - не участник
+func (a *API) organizationRole(userID, orgID uint) (string, error) {
+	return organizationRoleTx(a.DB, userID, orgID)
+}
+
+// Роль пользователя в организации в рамках транзакции tx
+func organizationRoleTx(tx *gorm.DB, userID, orgID uint) (string, error) {
+	var member model.OrganizationMember
+	err := tx.Where("organization_id = ? AND user_id = ?", orgID, userID).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return member.Role, nil
+}
+
+// Разрешение организации по имени с проверкой роли. Организации,
+// в которых пользователь не состоит, для него не существуют.
+func (a *API) resolveOrganization(user *model.User, name string, minRole string) (*model.Organization, string, error) {
+	var org model.Organization
+	err := a.DB.Where("name = ?", name).First(&org).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, "", errNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	role, err := a.organizationRole(user.ID, org.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	if role == "" {
+		return nil, "", errNotFound
+	}
+	if orgRoleRank(role) < orgRoleRank(minRole) {
+		return nil, "", errForbidden
+	}
+	return &org, role, nil
+}
+
+// Организация из пути запроса: /orgs/{orgname}
+func (a *API) requestOrganization(r *http.Request, minRole string) (*model.Organization, string, error) {
+	name, err := pathVar(r, "orgname")
+	if err != nil {
+		return nil, "", err
+	}
+	return a.resolveOrganization(currentUser(r), name, minRole)
+}
+
+// Может ли участник с данной ролью создавать проекты в организации
+func canCreateInOrganization(org *model.Organization, role string) bool {
+	if orgRoleRank(role) >= orgRoleRank(model.OrgRoleAdmin) {
+		return true
+	}
+	return role == model.OrgRoleMember && !org.RestrictProjectCreation
+}
+
+// Организация в ответах API
+type organizationView struct {
+	ID                      uint      `json:"id"`
+	Name                    string    `json:"name"`
+	DefaultVisibility       string    `json:"default_visibility"`
+	RestrictProjectCreation bool      `json:"restrict_project_creation"`
+	Role                    string    `json:"role"`
+	CreatedAt               time.Time `json:"created_at"`
+}
+
+func newOrganizationView(org *model.Organization, role string) organizationView {
+	return organizationView{
+		ID:                      org.ID,
+		Name:                    org.Name,
+		DefaultVisibility:       org.DefaultVisibility,
+		RestrictProjectCreation: org.RestrictProjectCreation,
+		Role:                    role,
+		CreatedAt:               org.CreatedAt,
+	}
+}
+
+// Создание организации; создатель становится её владельцем
+func (a *API) createOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		Name                    string `json:"name"`
+		DefaultVisibility       string `json:"default_visibility"`
+		RestrictProjectCreation bool   `json:"restrict_project_creation"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if request.Name == "" {
+		http.Error(w, "Organization name is required", http.StatusBadRequest)
+		return
+	}
+	if request.DefaultVisibility == "" {
+		request.DefaultVisibility = model.VisibilityPrivate
+	}
+	if !isVisibility(request.DefaultVisibility) {
+		http.Error(w, "Unknown visibility", http.StatusBadRequest)
+		return
+	}
+
+	var existing int64
+	err = a.DB.Unscoped().Model(&model.Organization{}).Where("name = ?", request.Name).Count(&existing).Error
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if existing > 0 {
+		http.Error(w, "Organization name is already taken", http.StatusConflict)
+		return
+	}
+
+	user := currentUser(r)
+	org := model.Organization{
+		Name:                    request.Name,
+		DefaultVisibility:       request.DefaultVisibility,
+		RestrictProjectCreation: request.RestrictProjectCreation,
+	}
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.OrganizationMember{
+			OrganizationID: org.ID,
+			UserID:         user.ID,
+			Role:           model.OrgRoleOwner,
+		}).Error
+	})
+	if err != nil {
+		http.Error(w, "Failed to create organization", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditOrgCreated, TargetType: auditTargetOrganization, TargetID: org.ID, After: org})
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newOrganizationView(&org, model.OrgRoleOwner))
+}
+
+// Организации текущего пользователя
+func (a *API) getOrganizationsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user := currentUser(r)
+
+	var memberships []model.OrganizationMember
+	err := a.DB.Where("user_id = ?", user.ID).Find(&memberships).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch organizations", http.StatusInternalServerError)
+		return
+	}
+	roles := make(map[uint]string, len(memberships))
+	ids := make([]uint, 0, len(memberships))
+	for _, member := range memberships {
+		roles[member.OrganizationID] = member.Role
+		ids = append(ids, member.OrganizationID)
+	}
+
+	var orgs []model.Organization
+	if len(ids) > 0 {
+		err = a.DB.Where("id IN ?", ids).Order("name").Find(&orgs).Error
+		if err != nil {
+			http.Error(w, "Failed to fetch organizations", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	views := make([]organizationView, 0, len(orgs))
+	for i := range orgs {
+		views = append(views, newOrganizationView(&orgs[i], roles[orgs[i].ID]))
+	}
+	json.NewEncoder(w).Encode(views)
+}
+
+// Сведения об организации
+func (a *API) getOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	org, role, err := a.requestOrganization(r, model.OrgRoleMember)
+	if err != nil {
+		writeResolveError(w, err, "Organization not found")
+		return
+	}
+	json.NewEncoder(w).Encode(newOrganizationView(org, role))
+}
+
+// Изменение названия и настроек организации (владельцы и администраторы)
+func (a *API) updateOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		Name                    *string `json:"name"`
+		DefaultVisibility       *string `json:"default_visibility"`
+		RestrictProjectCreation *bool   `json:"restrict_project_creation"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	org, role, err := a.requestOrganization(r, model.OrgRoleAdmin)
+	if err != nil {
+		writeResolveError(w, err, "Organization not found")
+		return
+	}
+
+	before := *org
+	updates := map[string]interface{}{}
+	if request.Name != nil && *request.Name != org.Name {
+		if *request.Name == "" {
+			http.Error(w, "Organization name is required", http.StatusBadRequest)
+			return
+		}
+		var existing int64
+		err = a.DB.Unscoped().Model(&model.Organization{}).Where("name = ?", *request.Name).Count(&existing).Error
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if existing > 0 {
+			http.Error(w, "Organization name is already taken", http.StatusConflict)
+			return
+		}
+		updates["name"] = *request.Name
+		org.Name = *request.Name
+	}
+	if request.DefaultVisibility != nil {
+		if !isVisibility(*request.DefaultVisibility) {
+			http.Error(w, "Unknown visibility", http.StatusBadRequest)
+			return
+		}
+		updates["default_visibility"] = *request.DefaultVisibility
+		org.DefaultVisibility = *request.DefaultVisibility
+	}
+	if request.RestrictProjectCreation != nil {
+		updates["restrict_project_creation"] = *request.RestrictProjectCreation
+		org.RestrictProjectCreation = *request.RestrictProjectCreation
+	}
+
+	if len(updates) > 0 {
+		err = a.DB.Model(&before).Updates(updates).Error
+		if err != nil {
+			http.Error(w, "Failed to update organization", http.StatusInternalServerError)
+			return
+		}
+		a.audit(r, auditEntry{Action: auditOrgUpdated, TargetType: auditTargetOrganization, TargetID: org.ID, Before: before, After: *org})
+	}
+
+	json.NewEncoder(w).Encode(newOrganizationView(org, role))
+}
+
+// Удаление организации (только владельцы). Проекты организации нужно
+// предварительно передать пользователям.
+func (a *API) deleteOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	org, _, err := a.requestOrganization(r, model.OrgRoleOwner)
+	if err != nil {
+		writeResolveError(w, err, "Organization not found")
+		return
+	}
+
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		var projects int64
+		err := tx.Unscoped().Model(&model.Project{}).Where("organization_id = ?", org.ID).Count(&projects).Error
+		if err != nil {
+			return err
+		}
+		if projects > 0 {
+			return errOrgNotEmpty
+		}
+
+		err = tx.Unscoped().Where("organization_id = ?", org.ID).Delete(&model.OrganizationMember{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(org).Error
+	})
+	if errors.Is(err, errOrgNotEmpty) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to delete organization", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditOrgDeleted, TargetType: auditTargetOrganization, TargetID: org.ID, Before: *org})
+
+	response := map[string]string{
+		"message": "Organization deleted",
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Участник организации в ответах API
+type orgMemberView struct {
+	UserID   uint      `json:"user_id"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+	Since    time.Time `json:"since"`
+}
+
+// Участники организации
+func (a *API) getOrganizationMembersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	org, _, err := a.requestOrganization(r, model.OrgRoleMember)
+	if err != nil {
+		writeResolveError(w, err, "Organization not found")
+		return
+	}
+
+	var members []orgMemberView
+	err = a.DB.Table("organization_members").
+		Select("organization_members.user_id, users.username, organization_members.role, organization_members.created_at AS since").
+		Joins("JOIN users ON users.id = organization_members.user_id").
+		Where("organization_members.organization_id = ? AND organization_members.deleted_at IS NULL", org.ID).
+		Order("organization_members.id").
+		Scan(&members).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch members", http.StatusInternalServerError)
+		return
+	}
+	if members == nil {
+		members = []orgMemberView{}
+	}
+	json.NewEncoder(w).Encode(members)
+}
+
+// Добавление пользователя в организацию (владельцы и администраторы).
+// Назначать владельцев могут только владельцы.
+func (a *API) addOrganizationMemberHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		Username string `json:"username"`
+		Role     string `json:"role"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.Role == "" {
+		request.Role = model.OrgRoleMember
+	}
+	if orgRoleRank(request.Role) == 0 {
+		http.Error(w, "Unknown role", http.StatusBadRequest)
+		return
+	}
+
+	org, role, err := a.requestOrganization(r, model.OrgRoleAdmin)
+	if err != nil {
+		writeResolveError(w, err, "Organization not found")
+		return
+	}
+	if request.Role == model.OrgRoleOwner && role != model.OrgRoleOwner {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var user model.User
+	err = a.DB.Where("username = ?", request.Username).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	existing, err := a.organizationRole(user.ID, org.ID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if existing != "" {
+		http.Error(w, errAlreadyMember.Error(), http.StatusConflict)
+		return
+	}
+
+	member := model.OrganizationMember{OrganizationID: org.ID, UserID: user.ID, Role: request.Role}
+	err = a.DB.Create(&member).Error
+	if err != nil {
+		http.Error(w, "Failed to add member", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{
+		Action:     auditOrgMemberAdded,
+		TargetType: auditTargetOrganization,
+		TargetID:   org.ID,
+		After:      map[string]interface{}{"UserID": user.ID, "Role": request.Role},
+	})
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(orgMemberView{UserID: user.ID, Username: user.Username, Role: member.Role, Since: member.CreatedAt})
+}
+
+// Участник организации из пути запроса
+func (a *API) requestOrganizationMember(w http.ResponseWriter, r *http.Request, org *model.Organization) (*model.OrganizationMember, bool) {
+	userID, err := strconv.ParseUint(mux.Vars(r)["userid"], 10, 64)
+	if err != nil {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	var member model.OrganizationMember
+	err = a.DB.Where("organization_id = ? AND user_id = ?", org.ID, userID).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return &member, true
+}
+
+// Проверка, что у организации останется другой владелец
+func ensureOtherOrgOwner(tx *gorm.DB, member *model.OrganizationMember) error {
+	if member.Role != model.OrgRoleOwner {
+		return nil
+	}
+
+	var owners int64
+	err := tx.Model(&model.OrganizationMember{}).
+		Where("organization_id = ? AND role = ? AND id <> ?", member.OrganizationID, model.OrgRoleOwner, member.ID).
+		Count(&owners).Error
+	if err != nil {
+		return err
+	}
+	if owners == 0 {
+		return errLastOrgOwner
+	}
+	return nil
+}
+
+// Изменение роли участника организации. Роли владельцев меняют
+// только владельцы; последнего владельца понизить нельзя.
+func (a *API) updateOrganizationMemberHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		Role string `json:"role"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if orgRoleRank(request.Role) == 0 {
+		http.Error(w, "Unknown role", http.StatusBadRequest)
+		return
+	}
+
+	org, role, err := a.requestOrganization(r, model.OrgRoleAdmin)
+	if err != nil {
+		writeResolveError(w, err, "Organization not found")
+		return
+	}
+
+	member, ok := a.requestOrganizationMember(w, r, org)
+	if !ok {
+		return
+	}
+	if (member.Role == model.OrgRoleOwner || request.Role == model.OrgRoleOwner) && role != model.OrgRoleOwner {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	before := *member
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		if request.Role != model.OrgRoleOwner {
+			if err := ensureOtherOrgOwner(tx, member); err != nil {
+				return err
+			}
+		}
+		return tx.Model(member).Update("role", request.Role).Error
+	})
+	if errors.Is(err, errLastOrgOwner) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update member", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{
+		Action:     auditOrgMemberRole,
+		TargetType: auditTargetOrganization,
+		TargetID:   org.ID,
+		Before:     map[string]interface{}{"UserID": before.UserID, "Role": before.Role},
+		After:      map[string]interface{}{"UserID": before.UserID, "Role": request.Role},
+	})
+
+	response := map[string]string{
+		"message": "Member updated",
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Удаление участника из организации. Участник может выйти сам;
+// остальных удаляют администраторы, владельцев - только владельцы.
+// Вместе с участием удаляется доступ к проектам организации.
+func (a *API) removeOrganizationMemberHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	org, role, err := a.requestOrganization(r, model.OrgRoleMember)
+	if err != nil {
+		writeResolveError(w, err, "Organization not found")
+		return
+	}
+
+	member, ok := a.requestOrganizationMember(w, r, org)
+	if !ok {
+		return
+	}
+
+	if member.UserID != currentUser(r).ID {
+		required := model.OrgRoleAdmin
+		if member.Role == model.OrgRoleOwner {
+			required = model.OrgRoleOwner
+		}
+		if orgRoleRank(role) < orgRoleRank(required) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := ensureOtherOrgOwner(tx, member); err != nil {
+			return err
+		}
+
+		// Включая проекты в корзине: после восстановления доступ не должен вернуться
+		projects := tx.Unscoped().Model(&model.Project{}).Select("id").Where("organization_id = ?", org.ID)
+		err := tx.Unscoped().Where("user_id = ? AND project_id IN (?)", member.UserID, projects).Delete(&model.ProjectMember{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(member).Error
+	})
+	if errors.Is(err, errLastOrgOwner) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to remove member", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{
+		Action:     auditOrgMemberRemoved,
+		TargetType: auditTargetOrganization,
+		TargetID:   org.ID,
+		Before:     map[string]interface{}{"UserID": member.UserID, "Role": member.Role},
+	})
+
+	response := map[string]string{
+		"message": "Member removed",
+	}
+	json.NewEncoder(w).Encode(response)
+}
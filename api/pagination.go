@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// Поле сортировки списка проектов
+type projectSort struct {
+	name   string // Значение параметра sort без знака "-"
+	column string
+	desc   bool
+	time   bool // Значение столбца - время
+}
+
+// Допустимые поля сортировки списка проектов
+var projectSortColumns = map[string]projectSort{
+	"name":       {name: "name", column: "projects.name"},
+	"created_at": {name: "created_at", column: "projects.created_at", time: true},
+	"updated_at": {name: "updated_at", column: "projects.updated_at", time: true},
+}
+
+// Разбор параметра sort: имя поля, "-" в начале - по убыванию.
+// По умолчанию проекты идут в порядке создания.
+func parseProjectSort(value string) (projectSort, bool) {
+	if value == "" {
+		value = "created_at"
+	}
+	desc := strings.HasPrefix(value, "-")
+	sort, ok := projectSortColumns[strings.TrimPrefix(value, "-")]
+	sort.desc = desc
+	return sort, ok
+}
+
+// Порядок строк; идентификатор различает проекты с одинаковым значением
+func (s projectSort) order() string {
+	if s.desc {
+		return s.column + " DESC, projects.id DESC"
+	}
+	return s.column + ", projects.id"
+}
+
+// Курсор страницы: значение поля сортировки и идентификатор последней строки
+type pageCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+func (s projectSort) param() string {
+	if s.desc {
+		return "-" + s.name
+	}
+	return s.name
+}
+
+// Курсор после строки со значением value и идентификатором id
+func (s projectSort) cursor(value interface{}, id uint) string {
+	c := pageCursor{Sort: s.param(), ID: id}
+	switch v := value.(type) {
+	case time.Time:
+		c.Value = v.UTC().Format(time.RFC3339Nano)
+	case string:
+		c.Value = v
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Условие для строк после курсора. Курсор действителен только
+// для той же сортировки, с которой он был выдан.
+func (s projectSort) after(query *gorm.DB, encoded string) (*gorm.DB, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var c pageCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != s.param() {
+		return nil, errInvalidCursor
+	}
+
+	var value interface{} = c.Value
+	if s.time {
+		moment, err := time.Parse(time.RFC3339Nano, c.Value)
+		if err != nil {
+			return nil, errInvalidCursor
+		}
+		value = moment
+	}
+
+	op := ">"
+	if s.desc {
+		op = "<"
+	}
+	condition := "(" + s.column + " " + op + " ? OR (" + s.column + " = ? AND projects.id " + op + " ?))"
+	return query.Where(condition, value, value, c.ID), nil
+}
+
+// Экранирование символов шаблона LIKE
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
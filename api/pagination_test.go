@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+)
+
+// Соединение, которое только строит SQL без обращения к базе
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	database, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return database
+}
+
+// SQL и параметры запроса списка проектов после курсора
+func cursorQuery(t *testing.T, sort projectSort, cursor string) (string, []interface{}) {
+	t.Helper()
+
+	query, err := sort.after(dryRunDB(t).Model(&model.Project{}), cursor)
+	if err != nil {
+		t.Fatalf("after(%q): %v", cursor, err)
+	}
+	var projects []model.Project
+	statement := query.Order(sort.order()).Find(&projects).Statement
+	return statement.SQL.String(), statement.Vars
+}
+
+func TestParseProjectSort(t *testing.T) {
+	tests := []struct {
+		value  string
+		ok     bool
+		column string
+		desc   bool
+	}{
+		{"", true, "projects.created_at", false},
+		{"name", true, "projects.name", false},
+		{"-updated_at", true, "projects.updated_at", true},
+		{"id", false, "", false},
+		{"--name", false, "", false},
+	}
+	for _, tt := range tests {
+		sort, ok := parseProjectSort(tt.value)
+		if ok != tt.ok {
+			t.Errorf("parseProjectSort(%q): ok %v, want %v", tt.value, ok, tt.ok)
+			continue
+		}
+		if ok && (sort.column != tt.column || sort.desc != tt.desc) {
+			t.Errorf("parseProjectSort(%q) = %s desc=%v, want %s desc=%v", tt.value, sort.column, sort.desc, tt.column, tt.desc)
+		}
+	}
+}
+
+func TestProjectCursorRoundTrip(t *testing.T) {
+	moment := time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.FixedZone("MSK", 3*60*60))
+
+	tests := []struct {
+		sort  string
+		value interface{}
+		op    string
+		want  interface{}
+	}{
+		{"name", "Guide", ">", "Guide"},
+		{"-name", "Guide", "<", "Guide"},
+		{"created_at", moment, ">", moment.UTC()},
+		{"-updated_at", moment, "<", moment.UTC()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			sort, ok := parseProjectSort(tt.sort)
+			if !ok {
+				t.Fatalf("unknown sort %q", tt.sort)
+			}
+
+			sql, vars := cursorQuery(t, sort, sort.cursor(tt.value, 42))
+			condition := "(" + sort.column + " " + tt.op + " $1 OR (" + sort.column + " = $2 AND projects.id " + tt.op + " $3))"
+			if !strings.Contains(sql, condition) {
+				t.Fatalf("query %q has no condition %q", sql, condition)
+			}
+			if len(vars) != 3 {
+				t.Fatalf("query vars %v, want 3", vars)
+			}
+			for _, value := range vars[:2] {
+				if moment, ok := value.(time.Time); ok {
+					if !moment.Equal(tt.want.(time.Time)) {
+						t.Errorf("cursor value %v, want %v", moment, tt.want)
+					}
+				} else if value != tt.want {
+					t.Errorf("cursor value %v, want %v", value, tt.want)
+				}
+			}
+			if vars[2] != uint(42) {
+				t.Errorf("cursor id %v, want 42", vars[2])
+			}
+		})
+	}
+}
+
+func TestProjectCursorInvalid(t *testing.T) {
+	byName, _ := parseProjectSort("name")
+	byCreated, _ := parseProjectSort("created_at")
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	valid := byName.cursor("Guide", 42)
+	tampered := []byte(valid)
+	tampered[len(tampered)/2] ^= 1
+
+	tests := []struct {
+		name   string
+		sort   projectSort
+		cursor string
+	}{
+		{"not base64", byName, "not a cursor!"},
+		{"padded base64", byName, base64.URLEncoding.EncodeToString([]byte(`{"s":"name","v":"Guide","id":42}`))},
+		{"not json", byName, encode("name:Guide:42")},
+		{"tampered", byName, string(tampered)},
+		{"wrong field type", byName, encode(`{"s":"name","v":"Guide","id":"42"}`)},
+		{"no sort", byName, encode(`{"v":"Guide","id":42}`)},
+		{"other field", byCreated, valid},
+		{"other direction", projectSort{name: "name", column: "projects.name", desc: true}, valid},
+		{"bad time", byCreated, encode(`{"s":"created_at","v":"yesterday","id":42}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.sort.after(dryRunDB(t), tt.cursor)
+			if !errors.Is(err, errInvalidCursor) {
+				t.Fatalf("after(%q) under %s: got %v, want errInvalidCursor", tt.cursor, tt.sort.param(), err)
+			}
+		})
+	}
+}
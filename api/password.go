@@ -0,0 +1,239 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+	"github.com/roGal1k/golang-beginner/internal/mail"
+	"github.com/roGal1k/golang-beginner/internal/passwords"
+)
+
+// Запрос на восстановление пароля по имени пользователя или email.
+// Ответ одинаков вне зависимости от существования учётной записи.
+func (a *API) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		Login string `json:"login"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if request.Login == "" {
+		http.Error(w, "Username or email is required", http.StatusBadRequest)
+		return
+	}
+
+	var user model.User
+	err = a.DB.Where("username = ? OR (email = ? AND email <> '')", request.Login, request.Login).First(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err == nil && user.Email != "" {
+		err = a.sendPasswordReset(&user, "If you did not request a reset, ignore this message.")
+		if err != nil {
+			http.Error(w, "Failed to create reset token", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	response := map[string]string{
+		"message": "If the account exists, a password reset link has been sent",
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Отправка письма со ссылкой на смену пароля
+func (a *API) sendPasswordReset(user *model.User, note string) error {
+	ttl := time.Duration(a.Config.Auth.PasswordResetTTL)
+	token, err := a.createUserToken(user.ID, model.TokenPurposePasswordReset, ttl)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/password/reset?token=%s", a.Config.PublicURL, url.QueryEscape(token))
+	a.sendMail(mail.Message{
+		To:      user.Email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf("Hello, %s!\n\nTo reset your password, open the link below:\n%s\n\n"+
+			"The link is valid for %s and can be used once. %s\n", user.Username, link, ttl, note),
+	})
+	return nil
+}
+
+// Установка нового пароля по токену из письма.
+// Все сессии пользователя завершаются.
+func (a *API) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if request.Token == "" || request.Password == "" {
+		http.Error(w, "Token and password are required", http.StatusBadRequest)
+		return
+	}
+
+	var userID uint
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, request.Token, model.TokenPurposePasswordReset)
+		if err != nil {
+			return err
+		}
+		userID = token.UserID
+
+		// При нарушении политики токен не расходуется: транзакция откатывается
+		var user model.User
+		if err := tx.First(&user, token.UserID).Error; err != nil {
+			return err
+		}
+		if err := a.PasswordPolicy.Check(request.Password, user.Username); err != nil {
+			return err
+		}
+
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&model.User{}).Where("id = ?", token.UserID).Updates(map[string]interface{}{
+			"password":                string(hashedPassword),
+			"password_reset_required": false,
+			"token_version":           gorm.Expr("token_version + 1"),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&model.Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", token.UserID).
+			Update("revoked_at", time.Now()).Error
+	})
+	if errors.Is(err, errInvalidUserToken) || passwords.IsPolicyError(err) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{ActorID: &userID, Action: auditPasswordReset, TargetType: auditTargetUser, TargetID: userID})
+
+	response := map[string]string{
+		"message": "Password has been reset",
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Смена пароля текущим пользователем. Требуется текущий пароль, если он задан
+// (у пользователей, входящих только через внешнего провайдера, пароля нет).
+// Все сессии, кроме текущей, завершаются.
+func (a *API) changePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if request.NewPassword == "" {
+		http.Error(w, "New password is required", http.StatusBadRequest)
+		return
+	}
+
+	auth := currentAuth(r)
+	user := auth.User
+
+	if user.Password != "" {
+		// Подбор текущего пароля ограничивается тем же счётчиком, что и вход
+		userKey := userThrottleKey(user.Username)
+		retry, err := a.throttleRetryAfter(userKey)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if retry > 0 {
+			writeTooManyAttempts(w, retry)
+			return
+		}
+
+		err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.CurrentPassword))
+		if err != nil {
+			if !a.recordAttempt(w, userKey, a.Config.Auth.Throttle.UserFreeAttempts) {
+				return
+			}
+			http.Error(w, "Invalid current password", http.StatusForbidden)
+			return
+		}
+	}
+
+	err = a.PasswordPolicy.Check(request.NewPassword, user.Username)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		return
+	}
+
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(user).Update("password", string(hashedPassword)).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&model.Session{}).
+			Where("user_id = ? AND id <> ? AND revoked_at IS NULL", user.ID, auth.Claims.SessionID).
+			Update("revoked_at", time.Now()).Error
+	})
+	if err != nil {
+		http.Error(w, "Failed to change password", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditPasswordChanged, TargetType: auditTargetUser, TargetID: user.ID})
+
+	if user.Email != "" {
+		a.sendMail(mail.Message{
+			To:      user.Email,
+			Subject: "Password changed",
+			Body: fmt.Sprintf("Hello, %s!\n\nThe password for your account was changed and other sessions were signed out.\n"+
+				"If this was not you, reset your password immediately.\n", user.Username),
+		})
+	}
+
+	response := map[string]string{
+		"message": "Password changed",
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,228 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+)
+
+// Префикс персональных токенов: позволяет отличить их от JWT
+// и находить случайно опубликованные токены
+const patPrefix = "pat_"
+
+// Права доступа персональных токенов
+const (
+	scopeProjectsRead  = "projects:read"
+	scopeProjectsWrite = "projects:write"
+	scopeContentRead   = "content:read"
+	scopeContentWrite  = "content:write"
+)
+
+var knownScopes = []string{scopeProjectsRead, scopeProjectsWrite, scopeContentRead, scopeContentWrite}
+
+// Как часто обновляется отметка последнего использования токена
+const lastUsedResolution = time.Minute
+
+// Проверка персонального токена доступа
+func (a *API) authorizePersonalToken(raw string) (*authInfo, error) {
+	var token model.PersonalAccessToken
+	err := a.DB.Where("token_hash = ?", hashToken(raw)).First(&token).Error
+	if err != nil {
+		return nil, fmt.Errorf("invalid token")
+	}
+	if token.RevokedAt != nil {
+		return nil, fmt.Errorf("token revoked")
+	}
+	if token.ExpiresAt != nil && time.Now().After(*token.ExpiresAt) {
+		return nil, fmt.Errorf("token expired")
+	}
+
+	var user model.User
+	err = a.DB.First(&user, token.UserID).Error
+	if err != nil {
+		return nil, fmt.Errorf("invalid token")
+	}
+	if user.DisabledAt != nil {
+		return nil, errAccountDisabled
+	}
+	// Токены, выданные до требования сменить пароль или запроса на удаление
+	// учётной записи, не действуют
+	if user.PasswordResetRequired || user.DeletionScheduledAt != nil {
+		return nil, fmt.Errorf("token revoked")
+	}
+
+	// Отметка использования не чаще раза в минуту
+	now := time.Now()
+	err = a.DB.Model(&model.PersonalAccessToken{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", token.ID, now.Add(-lastUsedResolution)).
+		Update("last_used_at", now).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify token")
+	}
+
+	return &authInfo{User: &user, Token: &token}, nil
+}
+
+// Описание токена в ответах API (без секрета)
+type personalTokenView struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+func newPersonalTokenView(token *model.PersonalAccessToken) personalTokenView {
+	return personalTokenView{
+		ID:         token.ID,
+		Name:       token.Name,
+		Prefix:     token.Prefix,
+		Scopes:     strings.Fields(token.Scopes),
+		CreatedAt:  token.CreatedAt,
+		ExpiresAt:  token.ExpiresAt,
+		LastUsedAt: token.LastUsedAt,
+	}
+}
+
+// Список действующих персональных токенов пользователя
+func (a *API) getPersonalTokensHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var tokens []model.PersonalAccessToken
+	err := a.DB.Where("user_id = ? AND revoked_at IS NULL", currentUser(r).ID).
+		Order("created_at DESC").Find(&tokens).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch tokens", http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]personalTokenView, 0, len(tokens))
+	for i := range tokens {
+		views = append(views, newPersonalTokenView(&tokens[i]))
+	}
+	json.NewEncoder(w).Encode(views)
+}
+
+// Создание персонального токена. Секрет возвращается только в этом ответе.
+func (a *API) createPersonalTokenHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if request.Name == "" {
+		http.Error(w, "Token name is required", http.StatusBadRequest)
+		return
+	}
+	if len(request.Scopes) == 0 {
+		http.Error(w, "At least one scope is required", http.StatusBadRequest)
+		return
+	}
+	for _, scope := range request.Scopes {
+		if !isKnownScope(scope) {
+			http.Error(w, "Unknown scope: "+scope, http.StatusBadRequest)
+			return
+		}
+	}
+	if request.ExpiresAt != nil && request.ExpiresAt.Before(time.Now()) {
+		http.Error(w, "Expiration must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	secret, err := newOpaqueToken()
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	raw := patPrefix + secret
+
+	token := model.PersonalAccessToken{
+		UserID:    currentUser(r).ID,
+		Name:      request.Name,
+		Prefix:    raw[:len(patPrefix)+6],
+		TokenHash: hashToken(raw),
+		Scopes:    strings.Join(request.Scopes, " "),
+		ExpiresAt: request.ExpiresAt,
+	}
+	err = a.DB.Create(&token).Error
+	if err != nil {
+		http.Error(w, "Failed to save token", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{
+		Action:     auditTokenCreated,
+		TargetType: auditTargetToken,
+		TargetID:   token.ID,
+		Metadata:   map[string]interface{}{"name": token.Name, "scopes": request.Scopes},
+	})
+
+	w.WriteHeader(http.StatusCreated)
+	response := struct {
+		personalTokenView
+		Token string `json:"token"`
+	}{newPersonalTokenView(&token), raw}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Отзыв персонального токена
+func (a *API) revokePersonalTokenHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var token model.PersonalAccessToken
+	err := a.DB.Where("id = ? AND user_id = ? AND revoked_at IS NULL", mux.Vars(r)["id"], currentUser(r).ID).
+		First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Token not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	err = a.DB.Model(&token).Update("revoked_at", time.Now()).Error
+	if err != nil {
+		http.Error(w, "Failed to revoke token", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{
+		Action:     auditTokenRevoked,
+		TargetType: auditTargetToken,
+		TargetID:   token.ID,
+		Metadata:   map[string]interface{}{"name": token.Name},
+	})
+
+	response := map[string]string{
+		"message": "Token revoked",
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func isKnownScope(scope string) bool {
+	for _, known := range knownScopes {
+		if known == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -2,65 +2,276 @@ package api
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
+	"log"
 	"net/http"
-	"net/url"
-	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
 
-	"github.com/gorilla/mux"
 	"github.com/roGal1k/golang-beginner/assets/model"
 )
 
+var errTransferTarget = errors.New("projects can only be transferred between a user and an organization")
+
+// Проект в списке вместе с ролью пользователя. В кратком виде вместо
+// разделов с содержимым возвращается их количество.
+type projectView struct {
+	model.Project
+	// Скрывает поле проекта, чтобы в кратком виде разделы не выводились
+	Sections     []*model.Section `json:"sections,omitempty"`
+	SectionCount *int64           `json:"section_count,omitempty"`
+	ContentCount *int64           `json:"content_count,omitempty"`
+	Organization string           `json:"organization,omitempty"`
+	Role         string           `json:"role"`
+}
+
+// Размер страницы списка проектов
+const (
+	defaultProjectsLimit = 50
+	maxProjectsLimit     = 200
+)
+
 // Get projects list
 func (a *API) getProjectsHandler(w http.ResponseWriter, r *http.Request) {
+	a.listProjects(w, r, false)
+}
+
+// Список проектов или шаблонов, доступных пользователю, постранично.
+// Фильтры: organization, q (часть названия), tag (можно несколько - нужны
+// все), created_since, created_until, updated_since, updated_until (RFC 3339).
+// sort - name, created_at или updated_at, с "-" в начале - по убыванию.
+// view=summary вместо разделов с содержимым возвращает их количество.
+// Страницы листаются параметром cursor: в ответе next_cursor для следующей.
+func (a *API) listProjects(w http.ResponseWriter, r *http.Request, template bool) {
 	w.Header().Set("Content-Type", "application/json")
 
-	claims, err := a.authorization(r)
+	user := currentUser(r)
+	params := r.URL.Query()
+
+	query := a.accessibleProjects(user).Where("projects.is_template = ?", template)
+	if name := params.Get("organization"); name != "" {
+		org, _, err := a.resolveOrganization(user, name, model.OrgRoleMember)
+		if err != nil {
+			writeResolveError(w, err, "Organization not found")
+			return
+		}
+		query = query.Where("projects.organization_id = ?", org.ID)
+	}
+	if q := strings.TrimSpace(params.Get("q")); q != "" {
+		query = query.Where("projects.name ILIKE ?", "%"+escapeLike(q)+"%")
+	}
+	tags, err := normalizeTags(params["tag"])
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	for _, tag := range tags {
+		query = query.Where("EXISTS (?)", a.DB.Model(&model.ProjectTag{}).Select("1").
+			Where("project_tags.project_id = projects.id AND project_tags.name = ?", tag))
+	}
+	for _, bound := range []struct{ param, condition string }{
+		{"created_since", "projects.created_at >= ?"},
+		{"created_until", "projects.created_at < ?"},
+		{"updated_since", "projects.updated_at >= ?"},
+		{"updated_until", "projects.updated_at < ?"},
+	} {
+		value := params.Get(bound.param)
+		if value == "" {
+			continue
+		}
+		moment, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			http.Error(w, "Invalid "+bound.param, http.StatusBadRequest)
+			return
+		}
+		query = query.Where(bound.condition, moment)
+	}
 
-	// Получение пользователя из базы данных
-	var user model.User
-	err = a.DB.Where("username = ?", claims.Username).First(&user).Error
-	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+	sort, ok := parseProjectSort(params.Get("sort"))
+	if !ok {
+		http.Error(w, "Unknown sort", http.StatusBadRequest)
+		return
+	}
+	if cursor := params.Get("cursor"); cursor != "" {
+		query, err = sort.after(query, cursor)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+	}
+
+	summary := false
+	switch params.Get("view") {
+	case "", "full":
+		query = query.Preload(projectSectionsPreload(r))
+	case "summary":
+		summary = true
+	default:
+		http.Error(w, "Unknown view", http.StatusBadRequest)
+		return
+	}
+
+	limit, err := queryInt(params.Get("limit"), defaultProjectsLimit)
+	if err != nil || limit <= 0 || limit > maxProjectsLimit {
+		http.Error(w, "Invalid limit", http.StatusBadRequest)
 		return
 	}
 
-	// Получение списка проектов пользователя
+	// Получение страницы личных, общих проектов и проектов организаций;
+	// лишняя строка показывает, есть ли следующая страница
 	var projects []model.Project
-	err = a.DB.Where("user_id = ?", user.ID).Preload("Sections.Contents").Find(&projects).Error
+	err = query.Order(sort.order()).Limit(limit + 1).Find(&projects).Error
 	if err != nil {
 		http.Error(w, "Failed to fetch projects", http.StatusInternalServerError)
 		return
 	}
+	nextCursor := ""
+	if len(projects) > limit {
+		projects = projects[:limit]
+		last := projects[limit-1]
+		var value interface{}
+		switch sort.name {
+		case "name":
+			value = last.Name
+		case "created_at":
+			value = last.CreatedAt
+		case "updated_at":
+			value = last.UpdatedAt
+		}
+		nextCursor = sort.cursor(value, last.ID)
+	}
 
-	// Отправка списка проектов в ответе
-	json.NewEncoder(w).Encode(projects)
+	views, err := a.projectViews(user, projects, summary)
+	if err != nil {
+		http.Error(w, "Failed to fetch projects", http.StatusInternalServerError)
+		return
+	}
+
+	// Отправка страницы проектов в ответе
+	response := map[string]interface{}{
+		"projects": views,
+	}
+	if nextCursor != "" {
+		response["next_cursor"] = nextCursor
+	}
+	json.NewEncoder(w).Encode(response)
 }
 
-// Create project
-func (a *API) createProjectHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+// Проекты страницы с ролью пользователя, организацией, метками
+// и, в кратком виде, количеством разделов и содержимого
+func (a *API) projectViews(user *model.User, projects []model.Project, summary bool) ([]projectView, error) {
+	roleOf, err := a.projectRoleLookup(user)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, 0, len(projects))
+	orgIDs := make([]uint, 0)
+	for _, project := range projects {
+		ids = append(ids, project.ID)
+		if project.OrganizationID != nil {
+			orgIDs = append(orgIDs, *project.OrganizationID)
+		}
+	}
+	orgNames := make(map[uint]string)
+	if len(orgIDs) > 0 {
+		var orgs []model.Organization
+		err = a.DB.Where("id IN ?", orgIDs).Find(&orgs).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, org := range orgs {
+			orgNames[org.ID] = org.Name
+		}
+	}
 
-	claims, err := a.authorization(r)
+	tags, err := a.projectTags(ids)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
-		return
+		return nil, err
+	}
+
+	var sectionCounts, contentCounts map[uint]int64
+	if summary {
+		sectionCounts, contentCounts, err = a.projectCounts(ids)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	var user model.User
-	err = a.DB.Where("username = ?", claims.Username).First(&user).Error
+	views := make([]projectView, 0, len(projects))
+	for i := range projects {
+		project := projects[i]
+		project.Tags = tags[project.ID]
+		view := projectView{Project: project, Sections: project.Sections, Role: roleOf(&projects[i])}
+		if project.OrganizationID != nil {
+			view.Organization = orgNames[*project.OrganizationID]
+		}
+		if summary {
+			sections, contents := sectionCounts[project.ID], contentCounts[project.ID]
+			view.SectionCount = &sections
+			view.ContentCount = &contents
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// Количество разделов и содержимого проектов по их идентификаторам
+func (a *API) projectCounts(projectIDs []uint) (map[uint]int64, map[uint]int64, error) {
+	sections := make(map[uint]int64, len(projectIDs))
+	contents := make(map[uint]int64, len(projectIDs))
+	if len(projectIDs) == 0 {
+		return sections, contents, nil
+	}
+
+	type count struct {
+		ProjectID uint
+		Total     int64
+	}
+
+	var rows []count
+	err := a.DB.Model(&model.Section{}).Select("project_id, COUNT(*) AS total").
+		Where("project_id IN ?", projectIDs).Group("project_id").Scan(&rows).Error
 	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
-		return
+		return nil, nil, err
+	}
+	for _, row := range rows {
+		sections[row.ProjectID] = row.Total
 	}
 
+	rows = nil
+	err = a.DB.Model(&model.Content{}).Select("sections.project_id, COUNT(*) AS total").
+		Joins("JOIN sections ON sections.id = contents.section_id AND sections.deleted_at IS NULL").
+		Where("sections.project_id IN ?", projectIDs).Group("sections.project_id").Scan(&rows).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, row := range rows {
+		contents[row.ProjectID] = row.Total
+	}
+	return sections, contents, nil
+}
+
+// Create project
+func (a *API) createProjectHandler(w http.ResponseWriter, r *http.Request) {
+	a.createProject(w, r, false)
+}
+
+// Создание проекта или шаблона. С полем organization проект создаётся
+// в организации, и создатель становится его участником с ролью owner.
+func (a *API) createProject(w http.ResponseWriter, r *http.Request, template bool) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user := currentUser(r)
+
 	// Создание нового проекта
-	var request model.Project
-	err = json.NewDecoder(r.Body).Decode(&request)
+	var request struct {
+		model.Project
+		Organization string `json:"organization"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -71,101 +282,376 @@ func (a *API) createProjectHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Project name is required", http.StatusBadRequest)
 		return
 	}
+	if request.Visibility != "" && !isVisibility(request.Visibility) {
+		http.Error(w, "Unknown visibility", http.StatusBadRequest)
+		return
+	}
+	request.Tags, err = normalizeTags(request.Tags)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	request.UserID = uint(user.ID)
-	fmt.Printf("UserID: %d, Username: %s\n", user.ID, claims.Username)
+	// Проект, разделы и содержимое собираются заново только из допустимых
+	// полей: идентификаторы из тела запроса не должны попасть в базу,
+	// иначе чужие разделы и содержимое перепривязываются к новому проекту
+	project := model.Project{
+		UserID:     user.ID,
+		Name:       request.Name,
+		IsTemplate: template,
+		Visibility: request.Visibility,
+		Tags:       request.Tags,
+		Sections:   newSectionsFromRequest(request.Sections),
+	}
+
+	if request.Organization != "" {
+		org, role, err := a.resolveOrganization(user, request.Organization, model.OrgRoleMember)
+		if err != nil {
+			writeResolveError(w, err, "Organization not found")
+			return
+		}
+		if !canCreateInOrganization(org, role) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		project.OrganizationID = &org.ID
+		if project.Visibility == "" {
+			project.Visibility = org.DefaultVisibility
+		}
+	} else {
+		project.Visibility = model.VisibilityPrivate
+	}
 
 	// Сохранение проекта в базе данных
-	result := a.DB.Create(&request)
-	if result.Error != nil {
-		http.Error(w, result.Error.Error(), http.StatusInternalServerError)
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		project.Slug, err = freeSlug(projectSlugScope(tx, &project), project.Name, model.SlugKindProject)
+		if err != nil {
+			return err
+		}
+		assignNewSectionSlugs(project.Sections)
+
+		if err := tx.Create(&project).Error; err != nil {
+			return err
+		}
+		if err := replaceProjectTags(tx, project.ID, project.Tags); err != nil {
+			return err
+		}
+		if project.OrganizationID == nil {
+			return nil
+		}
+		return tx.Create(&model.ProjectMember{ProjectID: project.ID, UserID: user.ID, Role: model.ProjectRoleOwner}).Error
+	})
+	if err != nil {
+		log.Printf("project create: %v", err)
+		http.Error(w, "Failed to create project", http.StatusInternalServerError)
 		return
 	}
 
+	a.audit(r, auditEntry{Action: auditProjectCreated, TargetType: auditTargetProject, TargetID: project.ID, After: project})
+
 	// Ответ пользователю
 	w.WriteHeader(http.StatusCreated)
 	response := map[string]string{
 		"message": "Project created successfully",
+		"slug":    project.Slug,
+	}
+	if template {
+		response["message"] = "Template created successfully"
 	}
 	json.NewEncoder(w).Encode(response)
 }
 
+// Новые разделы с содержимым из тела запроса без идентификаторов и связей
+func newSectionsFromRequest(sections []*model.Section) []*model.Section {
+	result := make([]*model.Section, 0, len(sections))
+	for _, section := range sections {
+		if section == nil {
+			continue
+		}
+		created := &model.Section{Title: section.Title, Slug: section.Slug}
+		for _, content := range section.Contents {
+			created.Contents = append(created.Contents, model.Content{Type: content.Type, Data: content.Data})
+		}
+		result = append(result, created)
+	}
+	return result
+}
+
 // Geted Project
 func (a *API) getProjectHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	userId := r.Header.Get("Id")
-	if userId == "" {
-		http.Error(w, "Authorization token required", http.StatusUnauthorized)
+	// Проект разрешается только среди доступных пользователю
+	project, err := a.requestProject(r, model.ProjectRoleViewer)
+	if err != nil {
+		writeResolveError(w, err, "Project not found")
 		return
 	}
-	// Извлечение имени проекта из URL
-	projectName := mux.Vars(r)["projectname"]
+	a.writeProject(w, r, project, http.StatusOK)
+}
+
+// Разделы проекта в ответе; содержимое разделов - только при праве content:read
+func projectSectionsPreload(r *http.Request) string {
+	if currentAuth(r).hasScope(scopeContentRead) {
+		return "Sections.Contents"
+	}
+	return "Sections"
+}
 
-	// Декодирование имени проекта из URL
-	decodedProjectName, err := url.QueryUnescape(projectName)
+// Ответ с проектом вместе с разделами, содержимым и метками
+func (a *API) writeProject(w http.ResponseWriter, r *http.Request, project *model.Project, status int) {
+	err := a.DB.Preload(projectSectionsPreload(r)).First(project, project.ID).Error
 	if err != nil {
-		http.Error(w, "Invalid project name", http.StatusBadRequest)
+		http.Error(w, "Failed to fetch project", http.StatusInternalServerError)
 		return
 	}
-
-	// Запрос к базе данных для получения проекта по его имени (или другому идентификатору)
-	var project model.Project
-	result := a.DB.Where("name = ? AND user_id = ?", decodedProjectName, userId).Preload("Sections.Contents").Find(&project)
-	if result.Error != nil {
-		http.Error(w, "Project not found", http.StatusNotFound)
+	tags, err := a.projectTags([]uint{project.ID})
+	if err != nil {
+		http.Error(w, "Failed to fetch project", http.StatusInternalServerError)
 		return
 	}
+	project.Tags = tags[project.ID]
 
 	// Ответ пользователю с информацией о проекте
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(project)
 }
 
 func (a *API) updateProjectHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	// Извлечение ID проекта из заголовка
-	projectIDStr := r.Header.Get("Id")
-	if projectIDStr == "" {
-		http.Error(w, "Project ID is required", http.StatusBadRequest)
-		return
-	}
-
-	// Преобразование ID проекта в uint
-	projectID, err := strconv.ParseUint(projectIDStr, 10, 32)
+	existingProject, err := a.requestProject(r, model.ProjectRoleEditor)
 	if err != nil {
-		http.Error(w, "Invalid project ID", http.StatusBadRequest)
-		return
-	}
-
-	// Запрос к базе данных для получения проекта по его ID
-	var existingProject model.Project
-	result := a.DB.First(&existingProject, uint(projectID))
-	if result.Error != nil {
-		http.Error(w, "Project not found", http.StatusNotFound)
+		writeResolveError(w, err, "Project not found")
 		return
 	}
+	a.updateProject(w, r, existingProject)
+}
 
+// Изменение названия и меток проекта (редакторы) и видимости в организации (владельцы).
+// Метки заменяются, только если поле tags передано.
+func (a *API) updateProject(w http.ResponseWriter, r *http.Request, existingProject *model.Project) {
 	// Декодирование JSON-данных с обновленной информацией о проекте
 	var updatedProject model.Project
-	err = json.NewDecoder(r.Body).Decode(&updatedProject)
+	err := json.NewDecoder(r.Body).Decode(&updatedProject)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Выполнение обновления проекта в базе данных
-	result = a.DB.Model(&existingProject).Updates(&updatedProject)
-	if result.Error != nil {
+	fields := []string{"Name"}
+	if updatedProject.Name == "" {
+		updatedProject.Name = existingProject.Name
+	}
+	if updatedProject.Tags != nil {
+		updatedProject.Tags, err = normalizeTags(updatedProject.Tags)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Прежние метки для журнала аудита
+		tags, err := a.projectTags([]uint{existingProject.ID})
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		existingProject.Tags = tags[existingProject.ID]
+	}
+	if updatedProject.Visibility != "" && updatedProject.Visibility != existingProject.Visibility {
+		if existingProject.OrganizationID == nil {
+			http.Error(w, "Visibility applies only to organization projects", http.StatusBadRequest)
+			return
+		}
+		if !isVisibility(updatedProject.Visibility) {
+			http.Error(w, "Unknown visibility", http.StatusBadRequest)
+			return
+		}
+		role, err := a.projectRole(currentUser(r), existingProject)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if role != model.ProjectRoleOwner {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		fields = append(fields, "Visibility")
+	}
+
+	// Выполнение обновления проекта в базе данных; владелец и идентификатор не меняются.
+	// При смене названия меняется адрес, прежний продолжает работать.
+	before := *existingProject
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := renameProjectSlug(tx, existingProject, updatedProject.Name); err != nil {
+			return err
+		}
+		if updatedProject.Tags != nil {
+			if err := replaceProjectTags(tx, existingProject.ID, updatedProject.Tags); err != nil {
+				return err
+			}
+		}
+		return tx.Model(existingProject).Select(fields).Updates(&updatedProject).Error
+	})
+	if err != nil {
 		http.Error(w, "Failed to update project", http.StatusInternalServerError)
 		return
 	}
 
+	after := before
+	after.Name = updatedProject.Name
+	after.Slug = existingProject.Slug
+	if len(fields) > 1 {
+		after.Visibility = updatedProject.Visibility
+	}
+	if updatedProject.Tags != nil {
+		after.Tags = updatedProject.Tags
+	}
+	a.audit(r, auditEntry{Action: auditProjectUpdated, TargetType: auditTargetProject, TargetID: existingProject.ID, Before: before, After: after})
+
 	// Ответ пользователю
 	w.WriteHeader(http.StatusOK)
 	response := map[string]string{
 		"message": "Project updated successfully",
+		"slug":    existingProject.Slug,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func (a *API) transferProjectHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	project, err := a.requestProject(r, model.ProjectRoleViewer)
+	if err != nil {
+		writeResolveError(w, err, "Project not found")
+		return
+	}
+	a.transferProject(w, r, project)
+}
+
+// Передача проекта между пользователем и организацией.
+// В организацию передаёт владелец проекта, если ему разрешено создавать
+// в ней проекты; прежний владелец остаётся участником с ролью owner.
+// Из организации передают её владельцы и администраторы одному из её участников.
+func (a *API) transferProject(w http.ResponseWriter, r *http.Request, project *model.Project) {
+	var request struct {
+		Organization string `json:"organization"`
+		Username     string `json:"username"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if (request.Organization == "") == (request.Username == "") {
+		http.Error(w, "Either organization or username is required", http.StatusBadRequest)
+		return
+	}
+
+	user := currentUser(r)
+	before := *project
+	after := *project
+
+	if request.Organization != "" {
+		if project.OrganizationID != nil {
+			http.Error(w, errTransferTarget.Error(), http.StatusBadRequest)
+			return
+		}
+		if project.UserID != user.ID {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		org, role, err := a.resolveOrganization(user, request.Organization, model.OrgRoleMember)
+		if err != nil {
+			writeResolveError(w, err, "Organization not found")
+			return
+		}
+		if !canCreateInOrganization(org, role) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		after.OrganizationID = &org.ID
+		after.Visibility = org.DefaultVisibility
+		err = a.DB.Transaction(func(tx *gorm.DB) error {
+			err := tx.Model(project).Updates(map[string]interface{}{
+				"organization_id": org.ID,
+				"visibility":      org.DefaultVisibility,
+			}).Error
+			if err != nil {
+				return err
+			}
+			if err := ensureProjectSlug(tx, &after); err != nil {
+				return err
+			}
+			return tx.Create(&model.ProjectMember{ProjectID: project.ID, UserID: user.ID, Role: model.ProjectRoleOwner}).Error
+		})
+	} else {
+		if project.OrganizationID == nil {
+			http.Error(w, errTransferTarget.Error(), http.StatusBadRequest)
+			return
+		}
+
+		role, err := a.organizationRole(user.ID, *project.OrganizationID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if orgRoleRank(role) < orgRoleRank(model.OrgRoleAdmin) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		var target model.User
+		err = a.DB.Where("username = ?", request.Username).First(&target).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		targetRole, err := a.organizationRole(target.ID, *project.OrganizationID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if targetRole == "" {
+			http.Error(w, "User is not a member of the organization", http.StatusBadRequest)
+			return
+		}
+
+		after.OrganizationID = nil
+		after.UserID = target.ID
+		after.Visibility = model.VisibilityPrivate
+		err = a.DB.Transaction(func(tx *gorm.DB) error {
+			err := tx.Model(project).Updates(map[string]interface{}{
+				"organization_id": nil,
+				"user_id":         target.ID,
+				"visibility":      model.VisibilityPrivate,
+			}).Error
+			if err != nil {
+				return err
+			}
+			if err := ensureProjectSlug(tx, &after); err != nil {
+				return err
+			}
+			// Владелец личного проекта не хранится среди участников
+			return tx.Unscoped().Where("project_id = ? AND user_id = ?", project.ID, target.ID).
+				Delete(&model.ProjectMember{}).Error
+		})
+	}
+	if err != nil {
+		http.Error(w, "Failed to transfer project", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditProjectTransferred, TargetType: auditTargetProject, TargetID: project.ID, Before: before, After: after})
+
+	response := map[string]string{
+		"message": "Project transferred",
+		"slug":    after.Slug,
 	}
 	json.NewEncoder(w).Encode(response)
 }
@@ -2,32 +2,25 @@ package api
 
 import (
 	"encoding/json"
-	"fmt"
+	"log"
 	"net/http"
-	"strconv"
 
-	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
 	"github.com/roGal1k/golang-beginner/assets/model"
 )
 
 func (a *API) createSectionHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	vars := mux.Vars(r)
-	projectName, ok := vars["projectname"]
-	if !ok {
-		http.Error(w, "Project name is required"+string(projectName), http.StatusBadRequest)
-		return
-	}
-
-	idProject := r.Header.Get("Id")
-	if idProject == "" {
-		http.Error(w, "id project is not found", http.StatusUnauthorized)
+	project, err := a.requestProject(r, model.ProjectRoleEditor)
+	if err != nil {
+		writeResolveError(w, err, "Project not found")
 		return
 	}
 
 	var request model.Section
-	err := json.NewDecoder(r.Body).Decode(&request)
+	err = json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -38,25 +31,33 @@ func (a *API) createSectionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	num, err := strconv.ParseUint(idProject, 10, 64)
+	// Раздел всегда создаётся в проекте из пути, а не из тела запроса
+	section := model.Section{
+		ProjectID: project.ID,
+		Title:     request.Title,
+	}
+
+	section.Slug, err = freeSlug(sectionSlugScope(a.DB, &section), section.Title, model.SlugKindSection)
 	if err != nil {
-		fmt.Println("Ошибка при конвертации:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
 
-	request.ProjectID = uint(num)
-
 	// Сохранение секции в базе данных
-	result := a.DB.Create(&request)
+	result := a.DB.Create(&section)
 	if result.Error != nil {
-		http.Error(w, result.Error.Error(), http.StatusInternalServerError)
+		log.Printf("section create: %v", result.Error)
+		http.Error(w, "Failed to create section", http.StatusInternalServerError)
 		return
 	}
 
+	a.audit(r, auditEntry{Action: auditSectionCreated, TargetType: auditTargetSection, TargetID: section.ID, After: section})
+
 	// Ответ пользователю
 	w.WriteHeader(http.StatusCreated)
 	response := map[string]string{
 		"message": "Section created successfully",
+		"slug":    section.Slug,
 	}
 	json.NewEncoder(w).Encode(response)
 }
@@ -64,71 +65,86 @@ func (a *API) createSectionHandler(w http.ResponseWriter, r *http.Request) {
 func (a *API) getSectionsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	// Извлечение значений из URL-параметров
-	vars := mux.Vars(r)
-	projectName, ok := vars["projectname"]
-	if !ok {
-		http.Error(w, "Project name is required"+string(projectName), http.StatusBadRequest)
+	project, err := a.requestProject(r, model.ProjectRoleViewer)
+	if err != nil {
+		writeResolveError(w, err, "Project not found")
 		return
 	}
-	sectionName, ok := vars["sectionname"]
-	if !ok {
-		http.Error(w, "Section name is required"+string(sectionName), http.StatusBadRequest)
+
+	var Sections []model.Section
+	err = a.DB.Where("project_id = ?", project.ID).Find(&Sections).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch sections", http.StatusInternalServerError)
 		return
 	}
 
-	idProject := r.Header.Get("Id")
-	if idProject == "" {
-		http.Error(w, "id project is not found", http.StatusUnauthorized)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Sections)
+}
+
+func (a *API) getSectionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	_, section, err := a.requestSection(r, model.ProjectRoleViewer)
+	if err != nil {
+		writeResolveError(w, err, "Section not found")
 		return
 	}
 
-	var Sections []model.Section
-	err := a.DB.Where("ptoject_id = ?", idProject).Find(&Sections).Error
+	err = a.DB.Model(section).Association("Contents").Find(&section.Contents)
 	if err != nil {
-		http.Error(w, "Failed to fetch projects", http.StatusInternalServerError)
+		http.Error(w, "Failed to fetch section", http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(Sections)
 	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(section)
 }
 
-func (a *API) getSectionHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) updateSectionHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	// Извлечение значений из URL-параметров
-	vars := mux.Vars(r)
-
-	projectName, ok := vars["projectname"]
-	if !ok {
-		http.Error(w, "Project name is required"+string(projectName), http.StatusBadRequest)
+	_, section, err := a.requestSection(r, model.ProjectRoleEditor)
+	if err != nil {
+		writeResolveError(w, err, "Section not found")
 		return
 	}
 
-	sectionName, ok := vars["sectionname"]
-	if !ok {
-		http.Error(w, "Section name is required"+string(sectionName), http.StatusBadRequest)
+	var request model.Section
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	idSection := r.Header.Get("Id")
-	if idSection == "" {
-		http.Error(w, "id project is not found", http.StatusUnauthorized)
+	if request.Title == "" {
+		http.Error(w, "Section title is required", http.StatusBadRequest)
 		return
 	}
 
-	var Section []model.Section
-	err := a.DB.Where("section_id = ?", idSection).Find(&Section).Error
+	// Обновляется только название: перенос раздела в другой проект запрещён.
+	// При смене названия меняется адрес, прежний продолжает работать.
+	before := *section
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := renameSectionSlug(tx, section, request.Title); err != nil {
+			return err
+		}
+		return tx.Model(section).Select("Title").Updates(&request).Error
+	})
 	if err != nil {
-		http.Error(w, "Failed to fetch projects", http.StatusInternalServerError)
+		http.Error(w, "Failed to update section", http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(Section)
-	w.WriteHeader(http.StatusOK)
-}
-
-func (a *API) updateSectionHandler(w http.ResponseWriter, r *http.Request) {
+	after := before
+	after.Title = request.Title
+	after.Slug = section.Slug
+	a.audit(r, auditEntry{Action: auditSectionUpdated, TargetType: auditTargetSection, TargetID: section.ID, Before: before, After: after})
 
+	w.WriteHeader(http.StatusOK)
+	response := map[string]string{
+		"message": "Section updated successfully",
+		"slug":    section.Slug,
+	}
+	json.NewEncoder(w).Encode(response)
 }
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+)
+
+// Максимальная длина сохраняемого User-Agent
+const maxUserAgentLength = 512
+
+// Устройство, с которого выполнен вход
+type sessionDevice struct {
+	UserAgent string
+	IP        string
+}
+
+func (a *API) requestDevice(r *http.Request) sessionDevice {
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	return sessionDevice{
+		UserAgent: userAgent,
+		IP:        a.clientIP(r),
+	}
+}
+
+// Описание сессии в ответах API
+type sessionView struct {
+	ID         uint      `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
+
+// Список активных сессий текущего пользователя
+func (a *API) getSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	auth := currentAuth(r)
+
+	// Сессия активна, пока не отозвана и у неё есть действующий refresh-токен
+	var sessions []model.Session
+	err := a.DB.Where("user_id = ? AND revoked_at IS NULL", auth.User.ID).
+		Where("EXISTS (?)", a.DB.Model(&model.RefreshToken{}).Select("1").
+			Where("refresh_tokens.session_id = sessions.id AND used_at IS NULL AND expires_at > ?", time.Now())).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch sessions", http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]sessionView, 0, len(sessions))
+	for _, session := range sessions {
+		views = append(views, sessionView{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			Current:    session.ID == auth.Claims.SessionID,
+		})
+	}
+	json.NewEncoder(w).Encode(views)
+}
+
+// Завершение одной сессии текущего пользователя
+func (a *API) revokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var session model.Session
+	err := a.DB.Where("id = ? AND user_id = ?", mux.Vars(r)["id"], currentUser(r).ID).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	err = a.revokeSession(session.ID)
+	if err != nil {
+		http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditSessionRevoked, TargetType: auditTargetSession, TargetID: session.ID})
+
+	response := map[string]string{
+		"message": "Session revoked",
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Завершение всех сессий, кроме текущей
+func (a *API) revokeOtherSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	auth := currentAuth(r)
+	result := a.DB.Model(&model.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", auth.User.ID, auth.Claims.SessionID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{
+		Action:     auditSessionRevoked,
+		TargetType: auditTargetUser,
+		TargetID:   auth.User.ID,
+		Metadata:   map[string]interface{}{"other_sessions": result.RowsAffected},
+	})
+
+	response := map[string]interface{}{
+		"message": "Other sessions revoked",
+		"revoked": result.RowsAffected,
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,289 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+)
+
+// Префикс токенов публичных ссылок
+const shareLinkPrefix = "shr_"
+
+// Заголовок с паролем для ссылок, защищённых паролем
+const sharePasswordHeader = "X-Share-Password"
+
+// Публичная ссылка в ответах API (без секрета)
+type shareLinkView struct {
+	ID           uint       `json:"id"`
+	Prefix       string     `json:"prefix"`
+	HasPassword  bool       `json:"has_password"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ViewCount    int64      `json:"view_count"`
+	LastViewedAt *time.Time `json:"last_viewed_at"`
+}
+
+func newShareLinkView(link *model.ShareLink) shareLinkView {
+	return shareLinkView{
+		ID:           link.ID,
+		Prefix:       link.Prefix,
+		HasPassword:  link.PasswordHash != "",
+		CreatedAt:    link.CreatedAt,
+		ExpiresAt:    link.ExpiresAt,
+		RevokedAt:    link.RevokedAt,
+		ViewCount:    link.ViewCount,
+		LastViewedAt: link.LastViewedAt,
+	}
+}
+
+// Проект, открытый по публичной ссылке: только название, разделы
+// и содержимое, без идентификаторов владельца и организации
+type sharedProjectView struct {
+	Name     string              `json:"name"`
+	Sections []sharedSectionView `json:"sections"`
+}
+
+type sharedSectionView struct {
+	Title    string              `json:"title"`
+	Slug     string              `json:"slug"`
+	Contents []sharedContentView `json:"contents"`
+}
+
+type sharedContentView struct {
+	Type string `json:"type"`
+	Data string `json:"data"`
+}
+
+func newSharedProjectView(project *model.Project) sharedProjectView {
+	view := sharedProjectView{Name: project.Name, Sections: make([]sharedSectionView, 0, len(project.Sections))}
+	for _, section := range project.Sections {
+		sectionView := sharedSectionView{
+			Title:    section.Title,
+			Slug:     section.Slug,
+			Contents: make([]sharedContentView, 0, len(section.Contents)),
+		}
+		for _, content := range section.Contents {
+			sectionView.Contents = append(sectionView.Contents, sharedContentView{Type: content.Type, Data: content.Data})
+		}
+		view.Sections = append(view.Sections, sectionView)
+	}
+	return view
+}
+
+// Создание публичной ссылки на проект (только владельцы).
+// Токен и адрес возвращаются только в этом ответе.
+func (a *API) createShareLinkHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		ExpiresAt *time.Time `json:"expires_at"`
+		Password  string     `json:"password"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.ExpiresAt != nil && request.ExpiresAt.Before(time.Now()) {
+		http.Error(w, "Expiration must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	project, err := a.requestProject(r, model.ProjectRoleOwner)
+	if err != nil {
+		writeResolveError(w, err, "Project not found")
+		return
+	}
+
+	secret, err := newOpaqueToken()
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	raw := shareLinkPrefix + secret
+
+	link := model.ShareLink{
+		ProjectID:   project.ID,
+		CreatedByID: currentUser(r).ID,
+		Prefix:      raw[:len(shareLinkPrefix)+6],
+		TokenHash:   hashToken(raw),
+		ExpiresAt:   request.ExpiresAt,
+	}
+	if request.Password != "" {
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+		if err != nil {
+			http.Error(w, "Invalid password", http.StatusBadRequest)
+			return
+		}
+		link.PasswordHash = string(hashedPassword)
+	}
+
+	err = a.DB.Create(&link).Error
+	if err != nil {
+		http.Error(w, "Failed to create share link", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{
+		Action:     auditShareLinkCreated,
+		TargetType: auditTargetProject,
+		TargetID:   project.ID,
+		Metadata: map[string]interface{}{
+			"share_link_id": link.ID,
+			"expires_at":    link.ExpiresAt,
+			"has_password":  link.PasswordHash != "",
+		},
+	})
+
+	w.WriteHeader(http.StatusCreated)
+	response := struct {
+		shareLinkView
+		Token string `json:"token"`
+		URL   string `json:"url"`
+	}{newShareLinkView(&link), raw, fmt.Sprintf("%s/s/%s", a.Config.PublicURL, url.PathEscape(raw))}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Публичные ссылки проекта вместе с числом просмотров (только владельцы)
+func (a *API) getShareLinksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	project, err := a.requestProject(r, model.ProjectRoleOwner)
+	if err != nil {
+		writeResolveError(w, err, "Project not found")
+		return
+	}
+
+	var links []model.ShareLink
+	err = a.DB.Where("project_id = ?", project.ID).Order("created_at DESC").Find(&links).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch share links", http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]shareLinkView, 0, len(links))
+	for i := range links {
+		views = append(views, newShareLinkView(&links[i]))
+	}
+	json.NewEncoder(w).Encode(views)
+}
+
+// Отзыв публичной ссылки (только владельцы). Запись остаётся
+// для истории просмотров.
+func (a *API) revokeShareLinkHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	project, err := a.requestProject(r, model.ProjectRoleOwner)
+	if err != nil {
+		writeResolveError(w, err, "Project not found")
+		return
+	}
+
+	result := a.DB.Model(&model.ShareLink{}).
+		Where("id = ? AND project_id = ? AND revoked_at IS NULL", mux.Vars(r)["id"], project.ID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		http.Error(w, "Failed to revoke share link", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "Share link not found", http.StatusNotFound)
+		return
+	}
+
+	a.audit(r, auditEntry{
+		Action:     auditShareLinkRevoked,
+		TargetType: auditTargetProject,
+		TargetID:   project.ID,
+		Metadata:   map[string]interface{}{"share_link_id": mux.Vars(r)["id"]},
+	})
+
+	response := map[string]string{
+		"message": "Share link revoked",
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Просмотр проекта по публичной ссылке без аутентификации. Пароль
+// передаётся в заголовке X-Share-Password; подбор ограничивается
+// так же, как подбор пароля при входе.
+func (a *API) sharedProjectHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Отозванная, просроченная и несуществующая ссылки неразличимы
+	var link model.ShareLink
+	err := a.DB.Where("token_hash = ?", hashToken(mux.Vars(r)["token"])).First(&link).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if err != nil || link.RevokedAt != nil || (link.ExpiresAt != nil && time.Now().After(*link.ExpiresAt)) {
+		http.Error(w, "Share link not found", http.StatusNotFound)
+		return
+	}
+
+	if link.PasswordHash != "" {
+		linkKey := shareThrottleKey(link.ID)
+		ipKey := ipThrottleKey(a.clientIP(r))
+		retry, err := a.throttleRetryAfter(linkKey, ipKey)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if retry > 0 {
+			writeTooManyAttempts(w, retry)
+			return
+		}
+
+		password := r.Header.Get(sharePasswordHeader)
+		if password == "" {
+			http.Error(w, "Password required", http.StatusUnauthorized)
+			return
+		}
+		err = bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password))
+		if err != nil {
+			if !a.recordAttempt(w, linkKey, a.Config.Auth.Throttle.UserFreeAttempts) ||
+				!a.recordAttempt(w, ipKey, a.Config.Auth.Throttle.IPFreeAttempts) {
+				return
+			}
+			http.Error(w, "Invalid password", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var project model.Project
+	err = a.DB.Preload("Sections.Contents").First(&project, link.ProjectID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Share link not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch project", http.StatusInternalServerError)
+		return
+	}
+
+	err = a.DB.Model(&model.ShareLink{}).Where("id = ?", link.ID).Updates(map[string]interface{}{
+		"view_count":     gorm.Expr("view_count + 1"),
+		"last_viewed_at": time.Now(),
+	}).Error
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// Ответ с проектом, его разделами и содержимым
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newSharedProjectView(&project))
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+)
+
+func TestSharedProjectViewFields(t *testing.T) {
+	orgID := uint(7)
+	project := &model.Project{
+		UserID:         3,
+		OrganizationID: &orgID,
+		Name:           "Guide",
+		Slug:           "guide",
+		Visibility:     model.VisibilityOrganization,
+		Tags:           []string{"internal"},
+		Sections: []*model.Section{{
+			ProjectID: 5,
+			Title:     "Intro",
+			Slug:      "intro",
+			Contents:  []model.Content{{SectionID: 9, Type: "text", Data: "hello"}},
+		}},
+	}
+	project.ID = 5
+
+	data, err := json.Marshal(newSharedProjectView(project))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"name": "Guide",
+		"sections": []interface{}{map[string]interface{}{
+			"title": "Intro",
+			"slug":  "intro",
+			"contents": []interface{}{map[string]interface{}{
+				"type": "text",
+				"data": "hello",
+			}},
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("shared project view:\n got %s\nwant %v", data, want)
+	}
+}
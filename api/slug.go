@@ -0,0 +1,260 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+	"github.com/roGal1k/golang-beginner/internal/slug"
+)
+
+// Объект найден по прежнему или ненормализованному адресу;
+// slug - текущий адрес
+type slugMovedError struct {
+	slug string
+}
+
+func (e *slugMovedError) Error() string {
+	return "moved to " + e.slug
+}
+
+// Перенаправление на текущий адрес объекта
+type movedError struct {
+	location string
+	status   int
+}
+
+func (e *movedError) Error() string {
+	return "moved to " + e.location
+}
+
+// Перенаправление запроса на адрес, в котором переменная пути name
+// заменена текущим адресом объекта. Безопасные запросы перенаправляются
+// с кодом 301, остальные - с кодом 308, чтобы клиент не сменил метод.
+func movedRequest(r *http.Request, name, value string) error {
+	vars := mux.Vars(r)
+	pairs := make([]string, 0, 2*len(vars))
+	for key, current := range vars {
+		if key == name {
+			current = value
+		}
+		pairs = append(pairs, key, current)
+	}
+
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return errNotFound
+	}
+	location, err := route.URLPath(pairs...)
+	if err != nil {
+		return err
+	}
+	location.RawQuery = r.URL.RawQuery
+
+	status := http.StatusMovedPermanently
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		status = http.StatusPermanentRedirect
+	}
+	return &movedError{location: location.String(), status: status}
+}
+
+// Проекты того же владельца (пользователя или организации) и вида,
+// включая удалённые: адрес не должен совпасть после восстановления
+func projectSlugScope(tx *gorm.DB, project *model.Project) *gorm.DB {
+	query := tx.Unscoped().Model(&model.Project{}).Where("is_template = ? AND id <> ?", project.IsTemplate, project.ID)
+	if project.OrganizationID != nil {
+		query = query.Where("organization_id = ?", *project.OrganizationID)
+	} else {
+		query = query.Where("organization_id IS NULL AND user_id = ?", project.UserID)
+	}
+	return query.Session(&gorm.Session{})
+}
+
+// Разделы того же проекта, включая удалённые
+func sectionSlugScope(tx *gorm.DB, section *model.Section) *gorm.DB {
+	return tx.Unscoped().Model(&model.Section{}).
+		Where("project_id = ? AND id <> ?", section.ProjectID, section.ID).
+		Session(&gorm.Session{})
+}
+
+// Свободный адрес для названия среди объектов scope. Если в названии
+// нет подходящих символов, адрес строится из fallback.
+func freeSlug(scope *gorm.DB, name, fallback string) (string, error) {
+	base := slug.Make(name)
+	if base == "" {
+		base = fallback
+	}
+
+	for n := 1; ; n++ {
+		candidate := base
+		if n > 1 {
+			candidate = slug.WithSuffix(base, n)
+		}
+
+		var taken int64
+		if err := scope.Where("slug = ?", candidate).Count(&taken).Error; err != nil {
+			return "", err
+		}
+		if taken == 0 {
+			return candidate, nil
+		}
+	}
+}
+
+// Уникальные адреса для разделов нового проекта, переданных вместе с ним.
+// Заданный адрес раздела сохраняется, если он не повторяется.
+func assignNewSectionSlugs(sections []*model.Section) {
+	taken := make(map[string]bool, len(sections))
+	for _, section := range sections {
+		base := slug.Make(section.Slug)
+		if base == "" {
+			base = slug.Make(section.Title)
+		}
+		if base == "" {
+			base = model.SlugKindSection
+		}
+
+		candidate := base
+		for n := 2; taken[candidate]; n++ {
+			candidate = slug.WithSuffix(base, n)
+		}
+		taken[candidate] = true
+		section.Slug = candidate
+	}
+}
+
+// Свободное название проекта среди объектов scope: при совпадении
+// к названию добавляется номер, например "Проект (2)"
+func freeProjectName(scope *gorm.DB, name string) (string, error) {
+	for n := 1; ; n++ {
+		candidate := name
+		if n > 1 {
+			candidate = fmt.Sprintf("%s (%d)", name, n)
+		}
+
+		var taken int64
+		if err := scope.Where("name = ?", candidate).Count(&taken).Error; err != nil {
+			return "", err
+		}
+		if taken == 0 {
+			return candidate, nil
+		}
+	}
+}
+
+// Сохранение прежнего адреса для перенаправления на объект
+func recordSlugAlias(tx *gorm.DB, kind string, parentID uint, oldSlug string, targetID uint) error {
+	if oldSlug == "" {
+		return nil
+	}
+	return tx.Create(&model.SlugAlias{Kind: kind, ParentID: parentID, Slug: oldSlug, TargetID: targetID}).Error
+}
+
+// Новый адрес проекта после переименования; прежний сохраняется
+func renameProjectSlug(tx *gorm.DB, project *model.Project, name string) error {
+	if slug.Make(name) == slug.Make(project.Name) {
+		return nil
+	}
+
+	newSlug, err := freeSlug(projectSlugScope(tx, project), name, model.SlugKindProject)
+	if err != nil {
+		return err
+	}
+	if newSlug == project.Slug {
+		return nil
+	}
+	return changeProjectSlug(tx, project, newSlug)
+}
+
+// Адрес проекта после смены владельца: сохраняется, если свободен
+// среди проектов нового владельца
+func ensureProjectSlug(tx *gorm.DB, project *model.Project) error {
+	var taken int64
+	err := projectSlugScope(tx, project).Where("slug = ?", project.Slug).Count(&taken).Error
+	if err != nil {
+		return err
+	}
+	if taken == 0 && project.Slug != "" {
+		return nil
+	}
+
+	newSlug, err := freeSlug(projectSlugScope(tx, project), project.Name, model.SlugKindProject)
+	if err != nil {
+		return err
+	}
+	return changeProjectSlug(tx, project, newSlug)
+}
+
+func changeProjectSlug(tx *gorm.DB, project *model.Project, newSlug string) error {
+	if err := recordSlugAlias(tx, model.SlugKindProject, 0, project.Slug, project.ID); err != nil {
+		return err
+	}
+	if err := tx.Model(project).Update("slug", newSlug).Error; err != nil {
+		return err
+	}
+	project.Slug = newSlug
+	return nil
+}
+
+// Новый адрес раздела после переименования; прежний сохраняется
+func renameSectionSlug(tx *gorm.DB, section *model.Section, title string) error {
+	if slug.Make(title) == slug.Make(section.Title) {
+		return nil
+	}
+
+	newSlug, err := freeSlug(sectionSlugScope(tx, section), title, model.SlugKindSection)
+	if err != nil {
+		return err
+	}
+	if newSlug == section.Slug {
+		return nil
+	}
+
+	if err := recordSlugAlias(tx, model.SlugKindSection, section.ProjectID, section.Slug, section.ID); err != nil {
+		return err
+	}
+	if err := tx.Model(section).Update("slug", newSlug).Error; err != nil {
+		return err
+	}
+	section.Slug = newSlug
+	return nil
+}
+
+// Заполнение адресов проектов и разделов, созданных до появления адресов
+func (a *API) BackfillSlugs() error {
+	var projects []model.Project
+	err := a.DB.Unscoped().Where("slug = '' OR slug IS NULL").Order("id").Find(&projects).Error
+	if err != nil {
+		return err
+	}
+	for i := range projects {
+		value, err := freeSlug(projectSlugScope(a.DB, &projects[i]), projects[i].Name, model.SlugKindProject)
+		if err != nil {
+			return err
+		}
+		err = a.DB.Unscoped().Model(&projects[i]).UpdateColumn("slug", value).Error
+		if err != nil {
+			return fmt.Errorf("project %d: %w", projects[i].ID, err)
+		}
+	}
+
+	var sections []model.Section
+	err = a.DB.Unscoped().Where("slug = '' OR slug IS NULL").Order("id").Find(&sections).Error
+	if err != nil {
+		return err
+	}
+	for i := range sections {
+		value, err := freeSlug(sectionSlugScope(a.DB, &sections[i]), sections[i].Title, model.SlugKindSection)
+		if err != nil {
+			return err
+		}
+		err = a.DB.Unscoped().Model(&sections[i]).UpdateColumn("slug", value).Error
+		if err != nil {
+			return fmt.Errorf("section %d: %w", sections[i].ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+)
+
+func TestAssignNewSectionSlugs(t *testing.T) {
+	sections := []*model.Section{
+		{Title: "Введение"},
+		{Title: "Введение"},
+		{Title: "Other", Slug: "vvedenie"},
+		{Title: "!!!"},
+		{Title: "???"},
+		{Title: "Custom", Slug: "My Slug"},
+	}
+	assignNewSectionSlugs(sections)
+
+	want := []string{"vvedenie", "vvedenie-2", "vvedenie-3", "section", "section-2", "my-slug"}
+	for i, section := range sections {
+		if section.Slug != want[i] {
+			t.Errorf("section %d (%q): slug %q, want %q", i, section.Title, section.Slug, want[i])
+		}
+	}
+}
+
+// Адрес с номером, если адрес из названия уже занят
+func TestFreeSlugSuffix(t *testing.T) {
+	a := newTestAPI(t)
+	user := createTestUser(t, a, "owner")
+
+	for i, want := range []string{"guide", "guide-2", "guide-3"} {
+		project := &model.Project{UserID: user.ID}
+		slug, err := freeSlug(projectSlugScope(a.DB, project), "Guide", model.SlugKindProject)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if slug != want {
+			t.Fatalf("project %d: slug %q, want %q", i+1, slug, want)
+		}
+		project.Name = "Guide"
+		project.Slug = slug
+		if err := a.DB.Create(project).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Название без подходящих символов
+	slug, err := freeSlug(projectSlugScope(a.DB, &model.Project{UserID: user.ID}), "!!!", model.SlugKindProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slug != model.SlugKindProject {
+		t.Fatalf("slug for punctuation-only name: %q, want %q", slug, model.SlugKindProject)
+	}
+}
@@ -0,0 +1,572 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+)
+
+// Размер страницы списка снимков
+const (
+	defaultSnapshotsLimit = 50
+	maxSnapshotsLimit     = 200
+)
+
+// Снимок в ответах API; разделы - только при запросе одного снимка
+type snapshotView struct {
+	ID           uint             `json:"id"`
+	Name         string           `json:"name,omitempty"`
+	Reason       string           `json:"reason"`
+	CreatedByID  *uint            `json:"created_by_id,omitempty"`
+	CreatedAt    time.Time        `json:"created_at"`
+	SectionCount int              `json:"section_count"`
+	ContentCount int              `json:"content_count"`
+	Sections     []archiveSection `json:"sections,omitempty"`
+}
+
+func newSnapshotView(snapshot *model.ProjectSnapshot) snapshotView {
+	return snapshotView{
+		ID:           snapshot.ID,
+		Name:         snapshot.Name,
+		Reason:       snapshot.Reason,
+		CreatedByID:  snapshot.CreatedByID,
+		CreatedAt:    snapshot.CreatedAt,
+		SectionCount: snapshot.SectionCount,
+		ContentCount: snapshot.ContentCount,
+	}
+}
+
+// Снимок текущих разделов и содержимого проекта
+func createSnapshotTx(tx *gorm.DB, projectID uint, createdByID *uint, name, reason string) (*model.ProjectSnapshot, error) {
+	tree, err := projectTree(tx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := newSnapshot(projectID, tree)
+	snapshot.CreatedByID = createdByID
+	snapshot.Name = name
+	snapshot.Reason = reason
+
+	if err := tx.Create(snapshot).Error; err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// Снимок дерева без сохранения
+func newSnapshot(projectID uint, tree []archiveSection) *model.ProjectSnapshot {
+	data, _ := json.Marshal(tree)
+	sum := sha256.Sum256(data)
+
+	snapshot := &model.ProjectSnapshot{
+		ProjectID:    projectID,
+		Checksum:     hex.EncodeToString(sum[:]),
+		SectionCount: len(tree),
+		Tree:         string(data),
+	}
+	for _, section := range tree {
+		snapshot.ContentCount += len(section.Contents)
+	}
+	return snapshot
+}
+
+// Разделы и содержимое из снимка
+func snapshotTree(snapshot *model.ProjectSnapshot) ([]archiveSection, error) {
+	var tree []archiveSection
+	err := json.Unmarshal([]byte(snapshot.Tree), &tree)
+	return tree, err
+}
+
+// Снимок проекта из пути запроса: /project/{projectname}/snapshots/{id}
+func (a *API) requestSnapshot(project *model.Project, id string) (*model.ProjectSnapshot, error) {
+	snapshotID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, errNotFound
+	}
+
+	var snapshot model.ProjectSnapshot
+	err = a.DB.Where("id = ? AND project_id = ?", snapshotID, project.ID).First(&snapshot).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// Список снимков проекта, от новых к старым. Страницы листаются
+// параметром before_id: в ответе next_before_id для следующей страницы.
+func (a *API) getSnapshotsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	project, err := a.requestProject(r, model.ProjectRoleViewer)
+	if err != nil {
+		writeResolveError(w, err, "Project not found")
+		return
+	}
+
+	params := r.URL.Query()
+	limit, err := queryInt(params.Get("limit"), defaultSnapshotsLimit)
+	if err != nil || limit <= 0 || limit > maxSnapshotsLimit {
+		http.Error(w, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+	query := a.DB.Omit("tree").Where("project_id = ?", project.ID)
+	if beforeID := params.Get("before_id"); beforeID != "" {
+		id, err := strconv.ParseUint(beforeID, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid before_id", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("id < ?", id)
+	}
+
+	var snapshots []model.ProjectSnapshot
+	err = query.Order("id DESC").Limit(limit).Find(&snapshots).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch snapshots", http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]snapshotView, 0, len(snapshots))
+	for i := range snapshots {
+		views = append(views, newSnapshotView(&snapshots[i]))
+	}
+	response := map[string]interface{}{
+		"snapshots": views,
+	}
+	if len(snapshots) == limit {
+		response["next_before_id"] = snapshots[len(snapshots)-1].ID
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Снимок проекта с названием
+func (a *API) createSnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	project, err := a.requestProject(r, model.ProjectRoleEditor)
+	if err != nil {
+		writeResolveError(w, err, "Project not found")
+		return
+	}
+
+	var request struct {
+		Name string `json:"name"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Name == "" {
+		http.Error(w, "Snapshot name is required", http.StatusBadRequest)
+		return
+	}
+
+	user := currentUser(r)
+	var snapshot *model.ProjectSnapshot
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		snapshot, err = createSnapshotTx(tx, project.ID, &user.ID, request.Name, model.SnapshotManual)
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Failed to create snapshot", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditSnapshotCreated, TargetType: auditTargetProject, TargetID: project.ID,
+		Metadata: map[string]interface{}{"snapshot_id": snapshot.ID, "name": snapshot.Name}})
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newSnapshotView(snapshot))
+}
+
+// Снимок вместе с разделами и содержимым
+func (a *API) getSnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	project, err := a.requestProject(r, model.ProjectRoleViewer)
+	if err != nil {
+		writeResolveError(w, err, "Project not found")
+		return
+	}
+	snapshot, err := a.requestSnapshot(project, mux.Vars(r)["id"])
+	if err != nil {
+		writeResolveError(w, err, "Snapshot not found")
+		return
+	}
+
+	view := newSnapshotView(snapshot)
+	view.Sections, err = snapshotTree(snapshot)
+	if err != nil {
+		http.Error(w, "Failed to read snapshot", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(view)
+}
+
+// Объекты и виды изменений в разнице между состояниями проекта
+const (
+	changeSection = "section"
+	changeContent = "content"
+	changeAdded   = "added"
+	changeRemoved = "removed"
+	changeChanged = "changed"
+)
+
+// Изменение раздела или содержимого между двумя состояниями проекта
+type snapshotChange struct {
+	Type   string      `json:"type"`   // section или content
+	ID     uint        `json:"id"`     // Идентификатор раздела или содержимого
+	Change string      `json:"change"` // added, removed или changed
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// Раздел без содержимого и содержимое с разделом для сравнения
+type sectionState struct {
+	Title string `json:"title"`
+	Slug  string `json:"slug"`
+}
+
+type contentState struct {
+	SectionID uint   `json:"section_id"`
+	Type      string `json:"type"`
+	Data      string `json:"data"`
+}
+
+// Разница между снимком и другим снимком (параметр to) или,
+// по умолчанию, текущим состоянием проекта
+func (a *API) diffSnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	project, err := a.requestProject(r, model.ProjectRoleViewer)
+	if err != nil {
+		writeResolveError(w, err, "Project not found")
+		return
+	}
+	snapshot, err := a.requestSnapshot(project, mux.Vars(r)["id"])
+	if err != nil {
+		writeResolveError(w, err, "Snapshot not found")
+		return
+	}
+	from, err := snapshotTree(snapshot)
+	if err != nil {
+		http.Error(w, "Failed to read snapshot", http.StatusInternalServerError)
+		return
+	}
+
+	var to []archiveSection
+	if target := r.URL.Query().Get("to"); target != "" && target != "current" {
+		other, err := a.requestSnapshot(project, target)
+		if err != nil {
+			writeResolveError(w, err, "Snapshot not found")
+			return
+		}
+		to, err = snapshotTree(other)
+		if err != nil {
+			http.Error(w, "Failed to read snapshot", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		to, err = projectTree(a.DB, project.ID)
+		if err != nil {
+			http.Error(w, "Failed to fetch project", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	response := map[string]interface{}{
+		"changes": diffTrees(from, to),
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Сравнение разделов и содержимого по идентификаторам
+func diffTrees(from, to []archiveSection) []snapshotChange {
+	changes := make([]snapshotChange, 0)
+
+	fromSections, fromContents, sectionOrder, contentOrder := flattenTree(from)
+	toSections, toContents, toSectionOrder, toContentOrder := flattenTree(to)
+
+	for _, id := range sectionOrder {
+		before := fromSections[id]
+		after, ok := toSections[id]
+		switch {
+		case !ok:
+			changes = append(changes, snapshotChange{Type: changeSection, ID: id, Change: changeRemoved, Before: before})
+		case after != before:
+			changes = append(changes, snapshotChange{Type: changeSection, ID: id, Change: changeChanged, Before: before, After: after})
+		}
+	}
+	for _, id := range toSectionOrder {
+		if _, ok := fromSections[id]; !ok {
+			changes = append(changes, snapshotChange{Type: changeSection, ID: id, Change: changeAdded, After: toSections[id]})
+		}
+	}
+
+	for _, id := range contentOrder {
+		before := fromContents[id]
+		after, ok := toContents[id]
+		switch {
+		case !ok:
+			changes = append(changes, snapshotChange{Type: changeContent, ID: id, Change: changeRemoved, Before: before})
+		case after != before:
+			changes = append(changes, snapshotChange{Type: changeContent, ID: id, Change: changeChanged, Before: before, After: after})
+		}
+	}
+	for _, id := range toContentOrder {
+		if _, ok := fromContents[id]; !ok {
+			changes = append(changes, snapshotChange{Type: changeContent, ID: id, Change: changeAdded, After: toContents[id]})
+		}
+	}
+	return changes
+}
+
+func flattenTree(tree []archiveSection) (map[uint]sectionState, map[uint]contentState, []uint, []uint) {
+	sections := make(map[uint]sectionState, len(tree))
+	contents := make(map[uint]contentState)
+	sectionOrder := make([]uint, 0, len(tree))
+	contentOrder := make([]uint, 0)
+	for _, section := range tree {
+		sections[section.ID] = sectionState{Title: section.Title, Slug: section.Slug}
+		sectionOrder = append(sectionOrder, section.ID)
+		for _, content := range section.Contents {
+			contents[content.ID] = contentState{SectionID: section.ID, Type: content.Type, Data: content.Data}
+			contentOrder = append(contentOrder, content.ID)
+		}
+	}
+	return sections, contents, sectionOrder, contentOrder
+}
+
+// Откат разделов и содержимого проекта к снимку. Перед откатом
+// сохраняется снимок текущего состояния, чтобы откат можно было отменить.
+func (a *API) rollbackSnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	project, err := a.requestProject(r, model.ProjectRoleEditor)
+	if err != nil {
+		writeResolveError(w, err, "Project not found")
+		return
+	}
+	snapshot, err := a.requestSnapshot(project, mux.Vars(r)["id"])
+	if err != nil {
+		writeResolveError(w, err, "Snapshot not found")
+		return
+	}
+	tree, err := snapshotTree(snapshot)
+	if err != nil {
+		http.Error(w, "Failed to read snapshot", http.StatusInternalServerError)
+		return
+	}
+
+	user := currentUser(r)
+	var saved *model.ProjectSnapshot
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		saved, err = createSnapshotTx(tx, project.ID, &user.ID, "", model.SnapshotRollback)
+		if err != nil {
+			return err
+		}
+		return rollbackTx(tx, project, tree, time.Now())
+	})
+	if err != nil {
+		http.Error(w, "Failed to roll back project", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditProjectRolledBack, TargetType: auditTargetProject, TargetID: project.ID,
+		Metadata: map[string]interface{}{"snapshot_id": snapshot.ID, "previous_snapshot_id": saved.ID}})
+
+	w.WriteHeader(http.StatusOK)
+	response := map[string]interface{}{
+		"message":              "Project rolled back",
+		"previous_snapshot_id": saved.ID,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Приведение разделов и содержимого проекта к дереву снимка. Объекты
+// сохраняют идентификаторы: удалённые восстанавливаются, лишние попадают
+// в корзину с моментом now, удалённые окончательно создаются заново.
+func rollbackTx(tx *gorm.DB, project *model.Project, tree []archiveSection, now time.Time) error {
+	var sections []model.Section
+	err := tx.Unscoped().Where("project_id = ?", project.ID).Find(&sections).Error
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[uint]bool, len(tree))
+	for _, section := range tree {
+		wanted[section.ID] = true
+	}
+	existing := make(map[uint]*model.Section, len(sections))
+	keep := make([]uint, 0, len(sections))
+	for i := range sections {
+		section := &sections[i]
+		if wanted[section.ID] {
+			existing[section.ID] = section
+			keep = append(keep, section.ID)
+			continue
+		}
+		// Разделы, которых нет в снимке, - в корзину вместе с содержимым
+		if !section.DeletedAt.Valid {
+			if err := trashSectionTx(tx, section, now); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Адреса восстанавливаемых разделов освобождаются, чтобы разделы
+	// могли обменяться адресами без нарушения уникальности
+	if len(keep) > 0 {
+		err = tx.Unscoped().Model(&model.Section{}).Where("id IN ?", keep).UpdateColumn("slug", "").Error
+		if err != nil {
+			return err
+		}
+	}
+
+	// Новые идентификаторы разделов, созданных заново
+	sectionIDs := make(map[uint]uint, len(tree))
+	contentIDs := make([]uint, 0)
+	for _, entry := range tree {
+		preferred := entry.Slug
+		if preferred == "" {
+			preferred = entry.Title
+		}
+
+		section, ok := existing[entry.ID]
+		if !ok {
+			section = &model.Section{ProjectID: project.ID, Title: entry.Title}
+		}
+		value, err := freeSlug(sectionSlugScope(tx, section), preferred, model.SlugKindSection)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			err = tx.Unscoped().Model(section).Updates(map[string]interface{}{
+				"title":      entry.Title,
+				"slug":       value,
+				"deleted_at": nil,
+			}).Error
+		} else {
+			section.Slug = value
+			err = tx.Create(section).Error
+		}
+		if err != nil {
+			return err
+		}
+		sectionIDs[entry.ID] = section.ID
+
+		for _, content := range entry.Contents {
+			contentIDs = append(contentIDs, content.ID)
+		}
+	}
+
+	// Содержимое проекта, включая удалённое
+	var contents []model.Content
+	projectSections := tx.Unscoped().Model(&model.Section{}).Select("id").Where("project_id = ?", project.ID)
+	err = tx.Unscoped().Where("section_id IN (?)", projectSections).Find(&contents).Error
+	if err != nil {
+		return err
+	}
+	snapshotContents := make(map[uint]bool, len(contentIDs))
+	for _, id := range contentIDs {
+		snapshotContents[id] = true
+	}
+	existingContents := make(map[uint]bool, len(contents))
+	for _, content := range contents {
+		if snapshotContents[content.ID] {
+			existingContents[content.ID] = true
+			continue
+		}
+		if !content.DeletedAt.Valid {
+			err := tx.Unscoped().Model(&model.Content{}).Where("id = ?", content.ID).UpdateColumn("deleted_at", now).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, entry := range tree {
+		sectionID := sectionIDs[entry.ID]
+		for _, content := range entry.Contents {
+			if existingContents[content.ID] {
+				err := tx.Unscoped().Model(&model.Content{}).Where("id = ?", content.ID).Updates(map[string]interface{}{
+					"section_id": sectionID,
+					"type":       content.Type,
+					"data":       content.Data,
+					"deleted_at": nil,
+				}).Error
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			err := tx.Create(&model.Content{SectionID: sectionID, Type: content.Type, Data: content.Data}).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Периодические снимки проектов, изменившихся с последнего снимка,
+// и удаление автоматических снимков сверх настроенного количества
+func (a *API) snapshotProjects() error {
+	var projects []model.Project
+	return a.DB.Select("id").FindInBatches(&projects, duplicateBatchSize, func(_ *gorm.DB, _ int) error {
+		for _, project := range projects {
+			if err := a.snapshotProject(project.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+func (a *API) snapshotProject(projectID uint) error {
+	return a.DB.Transaction(func(tx *gorm.DB) error {
+		tree, err := projectTree(tx, projectID)
+		if err != nil {
+			return err
+		}
+		snapshot := newSnapshot(projectID, tree)
+		snapshot.Reason = model.SnapshotScheduled
+
+		var last model.ProjectSnapshot
+		err = tx.Omit("tree").Where("project_id = ?", projectID).Order("id DESC").First(&last).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil && last.Checksum == snapshot.Checksum {
+			return nil
+		}
+		if err := tx.Create(snapshot).Error; err != nil {
+			return err
+		}
+
+		// Снимки с названием и снимки перед откатом не удаляются
+		if a.Config.Snapshots.Keep <= 0 {
+			return nil
+		}
+		outdated := tx.Model(&model.ProjectSnapshot{}).Select("id").
+			Where("project_id = ? AND reason = ?", projectID, model.SnapshotScheduled).
+			Order("id DESC").Offset(a.Config.Snapshots.Keep)
+		return tx.Unscoped().Where("id IN (?)", outdated).Delete(&model.ProjectSnapshot{}).Error
+	})
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+)
+
+// Ограничения меток проекта
+const (
+	maxProjectTags = 20
+	maxTagLength   = 32
+)
+
+var (
+	errTooManyTags = errors.New("too many tags")
+	errInvalidTag  = errors.New("tags must be non-empty and at most 32 characters long")
+)
+
+// Нормализация меток: без пробелов по краям, в нижнем регистре, без повторов
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, errInvalidTag
+		}
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > maxProjectTags {
+		return nil, errTooManyTags
+	}
+	return normalized, nil
+}
+
+// Замена меток проекта
+func replaceProjectTags(tx *gorm.DB, projectID uint, tags []string) error {
+	err := tx.Unscoped().Where("project_id = ?", projectID).Delete(&model.ProjectTag{}).Error
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	rows := make([]model.ProjectTag, 0, len(tags))
+	for _, tag := range tags {
+		rows = append(rows, model.ProjectTag{ProjectID: projectID, Name: tag})
+	}
+	return tx.Create(&rows).Error
+}
+
+// Метки проектов по их идентификаторам
+func (a *API) projectTags(projectIDs []uint) (map[uint][]string, error) {
+	tags := make(map[uint][]string, len(projectIDs))
+	if len(projectIDs) == 0 {
+		return tags, nil
+	}
+
+	var rows []model.ProjectTag
+	err := a.DB.Where("project_id IN ?", projectIDs).Order("project_id, name").Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		tags[row.ProjectID] = append(tags[row.ProjectID], row.Name)
+	}
+	return tags, nil
+}
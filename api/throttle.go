@@ -0,0 +1,208 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+)
+
+// Хеш для сравнения, когда пользователь не найден: время ответа
+// не должно выдавать существование учётной записи
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+// Ключи счётчиков неудачных попыток
+func userThrottleKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+func registerThrottleKey(ip string) string {
+	return "register:" + ip
+}
+
+func shareThrottleKey(linkID uint) string {
+	return fmt.Sprintf("share:%d", linkID)
+}
+
+// Адрес клиента; X-Forwarded-For учитывается только за доверенным прокси
+func (a *API) clientIP(r *http.Request) string {
+	if a.Config.TrustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Оставшееся время блокировки по самому строгому из ключей
+func (a *API) throttleRetryAfter(keys ...string) (time.Duration, error) {
+	var throttles []model.LoginThrottle
+	err := a.DB.Where("key IN ? AND locked_until > ?", keys, time.Now()).Find(&throttles).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var retry time.Duration
+	for _, t := range throttles {
+		if remaining := time.Until(*t.LockedUntil); remaining > retry {
+			retry = remaining
+		}
+	}
+	return retry, nil
+}
+
+// Учёт попытки в обработчике. Ошибка записи завершает запрос:
+// неучтённые попытки позволили бы обойти блокировку.
+func (a *API) recordAttempt(w http.ResponseWriter, key string, free int) bool {
+	if err := a.throttleHit(key, free); err != nil {
+		log.Printf("throttle %s: %v", key, err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// Учёт неудачной попытки. После free попыток каждая следующая
+// блокирует ключ на удваивающееся время, но не дольше MaxDelay.
+func (a *API) throttleHit(key string, free int) error {
+	cfg := a.Config.Auth.Throttle
+	now := time.Now()
+
+	return a.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&model.LoginThrottle{Key: key, LastFailureAt: now}).Error
+		if err != nil {
+			return err
+		}
+
+		var t model.LoginThrottle
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&t).Error
+		if err != nil {
+			return err
+		}
+
+		// Давние ошибки не учитываются
+		if now.Sub(t.LastFailureAt) > time.Duration(cfg.Window) {
+			t.Failures = 0
+		}
+		t.Failures++
+		t.LastFailureAt = now
+		t.LockedUntil = nil
+
+		if t.Failures >= free {
+			delay := time.Duration(cfg.BaseDelay)
+			for i := free; i < t.Failures && delay < time.Duration(cfg.MaxDelay); i++ {
+				delay *= 2
+			}
+			if delay > time.Duration(cfg.MaxDelay) {
+				delay = time.Duration(cfg.MaxDelay)
+			}
+			until := now.Add(delay)
+			t.LockedUntil = &until
+		}
+
+		return tx.Save(&t).Error
+	})
+}
+
+// Сброс счётчика после успешного входа
+func (a *API) throttleReset(key string) error {
+	return a.DB.Unscoped().Where("key = ?", key).Delete(&model.LoginThrottle{}).Error
+}
+
+// Ответ при активной блокировке
+func writeTooManyAttempts(w http.ResponseWriter, retry time.Duration) {
+	w.Header().Set("Retry-After", fmt.Sprint(int64(retry/time.Second)+1))
+	http.Error(w, "Too many attempts, try again later", http.StatusTooManyRequests)
+}
+
+// Список счётчиков с активной блокировкой или недавними ошибками
+func (a *API) getLockoutsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	since := time.Now().Add(-time.Duration(a.Config.Auth.Throttle.Window))
+
+	var throttles []model.LoginThrottle
+	query := a.DB.Where("locked_until > ? OR last_failure_at > ?", time.Now(), since)
+	if prefix := r.URL.Query().Get("key"); prefix != "" {
+		query = query.Where("key LIKE ?", prefix+"%")
+	}
+	err := query.Order("last_failure_at DESC").Find(&throttles).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch lockouts", http.StatusInternalServerError)
+		return
+	}
+
+	type lockoutView struct {
+		ID            uint       `json:"id"`
+		Key           string     `json:"key"`
+		Failures      int        `json:"failures"`
+		LastFailureAt time.Time  `json:"last_failure_at"`
+		LockedUntil   *time.Time `json:"locked_until"`
+	}
+	views := make([]lockoutView, 0, len(throttles))
+	for _, t := range throttles {
+		views = append(views, lockoutView{t.ID, t.Key, t.Failures, t.LastFailureAt, t.LockedUntil})
+	}
+	json.NewEncoder(w).Encode(views)
+}
+
+// Снятие блокировки
+func (a *API) clearLockoutHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Lockout not found", http.StatusNotFound)
+		return
+	}
+
+	var throttle model.LoginThrottle
+	err = a.DB.Where("id = ?", id).First(&throttle).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Lockout not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	err = a.DB.Unscoped().Delete(&throttle).Error
+	if err != nil {
+		http.Error(w, "Failed to clear lockout", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{
+		Action:     auditAdminLockoutClear,
+		TargetType: auditTargetLockout,
+		TargetID:   throttle.ID,
+		Metadata:   map[string]interface{}{"key": throttle.Key},
+	})
+
+	response := map[string]string{
+		"message": "Lockout cleared",
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,324 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"gorm.io/gorm"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+)
+
+type Claims struct {
+	Username  string `json:"username"`
+	Version   uint   `json:"ver"` // Версия токенов пользователя на момент выдачи
+	SessionID uint   `json:"sid"` // Сессия, к которой привязан токен
+	jwt.StandardClaims
+}
+
+var (
+	errInvalidRefreshToken = errors.New("invalid refresh token")
+	errRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// Пара токенов, выдаваемая при входе и обновлении
+type tokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// Генерация уникального идентификатора токена (jti)
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Генерация непрозрачного случайного токена
+func newOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Хеш токена для хранения в базе данных
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Выпуск короткоживущего access-токена в рамках сессии
+func (a *API) issueAccessToken(user *model.User, sessionID uint) (string, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		Username:  user.Username,
+		Version:   user.TokenVersion,
+		SessionID: sessionID,
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			Issuer:    a.Config.Auth.Issuer,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(a.accessTokenTTL()).Unix(),
+		},
+	}
+
+	return a.Keys.sign(claims)
+}
+
+// Срок действия access-токена
+func (a *API) accessTokenTTL() time.Duration {
+	return time.Duration(a.Config.Auth.AccessTokenTTL)
+}
+
+// Срок действия refresh-токена
+func (a *API) refreshTokenTTL() time.Duration {
+	return time.Duration(a.Config.Auth.RefreshTokenTTL)
+}
+
+// Создание refresh-токена сессии; в базе сохраняется только его хеш
+func createRefreshToken(tx *gorm.DB, sessionID uint, ttl time.Duration) (string, error) {
+	raw, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = tx.Create(&model.RefreshToken{
+		SessionID: sessionID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(ttl),
+	}).Error
+	if err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// Открытие новой сессии и выдача пары токенов
+func (a *API) createSession(user *model.User, device sessionDevice) (*tokenPair, error) {
+	var refreshToken string
+	session := model.Session{
+		UserID:     user.ID,
+		UserAgent:  device.UserAgent,
+		IP:         device.IP,
+		LastSeenAt: time.Now(),
+	}
+
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		var err error
+		refreshToken, err = createRefreshToken(tx, session.ID, a.refreshTokenTTL())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := a.issueAccessToken(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(a.accessTokenTTL() / time.Second),
+	}, nil
+}
+
+// Обмен refresh-токена на новую пару токенов (ротация).
+// Повторное предъявление уже использованного токена отзывает всю сессию.
+func (a *API) rotateRefreshToken(raw string, device sessionDevice) (*tokenPair, error) {
+	var stored model.RefreshToken
+	err := a.DB.Where("token_hash = ?", hashToken(raw)).First(&stored).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	var session model.Session
+	err = a.DB.First(&session, stored.SessionID).Error
+	if err != nil || session.RevokedAt != nil {
+		return nil, errInvalidRefreshToken
+	}
+
+	if stored.UsedAt != nil {
+		if err := a.revokeSession(session.ID); err != nil {
+			return nil, err
+		}
+		return nil, errRefreshTokenReused
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, errInvalidRefreshToken
+	}
+
+	var user model.User
+	err = a.DB.First(&user, session.UserID).Error
+	if err != nil || user.DisabledAt != nil {
+		return nil, errInvalidRefreshToken
+	}
+
+	var refreshToken string
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		// Условное обновление защищает от одновременной ротации одного токена
+		result := tx.Model(&model.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", stored.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errRefreshTokenReused
+		}
+
+		err := tx.Model(&session).Updates(map[string]interface{}{
+			"user_agent":   device.UserAgent,
+			"ip":           device.IP,
+			"last_seen_at": time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		refreshToken, err = createRefreshToken(tx, session.ID, a.refreshTokenTTL())
+		return err
+	})
+	if errors.Is(err, errRefreshTokenReused) {
+		if err := a.revokeSession(session.ID); err != nil {
+			return nil, err
+		}
+		return nil, errRefreshTokenReused
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := a.issueAccessToken(&user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(a.accessTokenTTL() / time.Second),
+	}, nil
+}
+
+// Отзыв сессии: её refresh-токены и access-токены перестают приниматься
+func (a *API) revokeSession(sessionID uint) error {
+	return a.DB.Model(&model.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// Отзыв всех сессий пользователя
+func (a *API) revokeUserSessions(userID uint) error {
+	return a.DB.Model(&model.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// Функция для верификации токена и получения информации о пользователе.
+// Принимаются access-токены сессий и персональные токены доступа.
+func (a *API) authorization(r *http.Request) (*authInfo, error) {
+	// Извлекаем токен из запроса
+	tokenString := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if tokenString == "" {
+		return nil, fmt.Errorf("authorization token required")
+	}
+
+	if strings.HasPrefix(tokenString, patPrefix) {
+		return a.authorizePersonalToken(tokenString)
+	}
+	return a.authorizeAccessToken(tokenString)
+}
+
+// Проверка access-токена сессии
+func (a *API) authorizeAccessToken(tokenString string) (*authInfo, error) {
+	// Верификация токена
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, a.Keys.keyFunc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	// Проверка валидности токена и извлечение информации о пользователе
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid || claims.Id == "" || claims.SessionID == 0 {
+		return nil, fmt.Errorf("invalid token")
+	}
+	if a.Config.Auth.Issuer != "" && !claims.VerifyIssuer(a.Config.Auth.Issuer, true) {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	// Проверка, что токен не был отозван
+	var revoked int64
+	err = a.DB.Model(&model.RevokedToken{}).Where("jti = ?", claims.Id).Count(&revoked).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify token")
+	}
+	if revoked > 0 {
+		return nil, fmt.Errorf("token revoked")
+	}
+
+	// Проверка версии токенов (выход со всех устройств)
+	var user model.User
+	err = a.DB.Where("username = ?", claims.Username).First(&user).Error
+	if err != nil || claims.Version != user.TokenVersion {
+		return nil, fmt.Errorf("token revoked")
+	}
+	if user.DisabledAt != nil {
+		return nil, errAccountDisabled
+	}
+
+	// Проверка, что сессия не была отозвана
+	var session model.Session
+	err = a.DB.Where("id = ? AND user_id = ?", claims.SessionID, user.ID).First(&session).Error
+	if err != nil || session.RevokedAt != nil {
+		return nil, fmt.Errorf("token revoked")
+	}
+
+	// Отметка активности сессии не чаще раза в минуту
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) > lastUsedResolution {
+		err = a.DB.Model(&session).Update("last_seen_at", now).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify token")
+		}
+	}
+
+	return &authInfo{User: &user, Claims: claims}, nil
+}
+
+// Отзыв токена по его jti до истечения срока действия
+func (a *API) revokeToken(user *model.User, claims *Claims) error {
+	return a.DB.Create(&model.RevokedToken{
+		JTI:       claims.Id,
+		UserID:    user.ID,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	}).Error
+}
@@ -0,0 +1,598 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+)
+
+// Типы объектов в корзине
+const (
+	trashProject  = "project"
+	trashTemplate = "template"
+	trashSection  = "section"
+	trashContent  = "content"
+)
+
+// Удаление в корзину. Дочерние объекты помечаются тем же моментом удаления,
+// что и родитель: при восстановлении возвращаются только они, а удалённые
+// раньше остаются в корзине.
+
+// Удаление проекта вместе с разделами и содержимым
+func trashProjectTx(tx *gorm.DB, project *model.Project, now time.Time) error {
+	sections := tx.Model(&model.Section{}).Select("id").Where("project_id = ?", project.ID)
+	err := tx.Model(&model.Content{}).Where("section_id IN (?)", sections).Update("deleted_at", now).Error
+	if err != nil {
+		return err
+	}
+	err = tx.Model(&model.Section{}).Where("project_id = ?", project.ID).Update("deleted_at", now).Error
+	if err != nil {
+		return err
+	}
+	return tx.Model(project).Update("deleted_at", now).Error
+}
+
+// Удаление раздела вместе с содержимым
+func trashSectionTx(tx *gorm.DB, section *model.Section, now time.Time) error {
+	err := tx.Model(&model.Content{}).Where("section_id = ?", section.ID).Update("deleted_at", now).Error
+	if err != nil {
+		return err
+	}
+	return tx.Model(section).Update("deleted_at", now).Error
+}
+
+func (a *API) deleteProjectHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	project, err := a.requestProject(r, model.ProjectRoleOwner)
+	if err != nil {
+		writeResolveError(w, err, "Project not found")
+		return
+	}
+	a.deleteProject(w, r, project)
+}
+
+func (a *API) deleteTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	template, err := a.requestTemplate(r, model.ProjectRoleOwner)
+	if err != nil {
+		writeResolveError(w, err, "Template not found")
+		return
+	}
+	a.deleteProject(w, r, template)
+}
+
+// Перемещение проекта или шаблона в корзину (только владельцы)
+func (a *API) deleteProject(w http.ResponseWriter, r *http.Request, project *model.Project) {
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		return trashProjectTx(tx, project, time.Now())
+	})
+	if err != nil {
+		http.Error(w, "Failed to delete project", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditProjectDeleted, TargetType: auditTargetProject, TargetID: project.ID, Before: *project})
+
+	response := map[string]string{
+		"message": "Project moved to trash",
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Перемещение раздела в корзину
+func (a *API) deleteSectionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	_, section, err := a.requestSection(r, model.ProjectRoleEditor)
+	if err != nil {
+		writeResolveError(w, err, "Section not found")
+		return
+	}
+
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		return trashSectionTx(tx, section, time.Now())
+	})
+	if err != nil {
+		http.Error(w, "Failed to delete section", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditSectionDeleted, TargetType: auditTargetSection, TargetID: section.ID, Before: *section})
+
+	response := map[string]string{
+		"message": "Section moved to trash",
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Перемещение содержимого в корзину
+func (a *API) deleteContentHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	_, content, err := a.requestContent(r, model.ProjectRoleEditor)
+	if err != nil {
+		writeResolveError(w, err, "Content not found")
+		return
+	}
+
+	err = a.DB.Delete(content).Error
+	if err != nil {
+		http.Error(w, "Failed to delete content", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditContentDeleted, TargetType: auditTargetContent, TargetID: content.ID, Before: *content})
+
+	response := map[string]string{
+		"message": "Content moved to trash",
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Объект корзины в ответах API
+type trashItemView struct {
+	Type      string    `json:"type"` // project, template, section или content
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"` // Название проекта, заголовок раздела или тип содержимого
+	Project   string    `json:"project,omitempty"`
+	Section   string    `json:"section,omitempty"`
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"` // Момент окончательного удаления
+}
+
+// Корзина пользователя: удалённые проекты, которыми он владеет, и удалённые
+// разделы и содержимое проектов, которые он может редактировать.
+// Объекты, удалённые вместе с родителем, отдельно не показываются.
+func (a *API) getTrashHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user := currentUser(r)
+	retention := time.Duration(a.Config.Trash.Retention)
+
+	roleOf, err := a.projectRoleLookup(user)
+	if err != nil {
+		http.Error(w, "Failed to fetch trash", http.StatusInternalServerError)
+		return
+	}
+
+	var deletedProjects []model.Project
+	err = a.accessibleProjects(user).Unscoped().Where("projects.deleted_at IS NOT NULL").
+		Order("projects.deleted_at DESC").Find(&deletedProjects).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch trash", http.StatusInternalServerError)
+		return
+	}
+
+	var liveProjects []model.Project
+	err = a.accessibleProjects(user).Find(&liveProjects).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch trash", http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]trashItemView, 0)
+	for i := range deletedProjects {
+		project := &deletedProjects[i]
+		if roleOf(project) != model.ProjectRoleOwner {
+			continue
+		}
+		kind := trashProject
+		if project.IsTemplate {
+			kind = trashTemplate
+		}
+		items = append(items, trashItemView{
+			Type:      kind,
+			ID:        project.ID,
+			Name:      project.Name,
+			DeletedAt: project.DeletedAt.Time,
+			PurgeAt:   project.DeletedAt.Time.Add(retention),
+		})
+	}
+
+	projectNames := make(map[uint]string)
+	projectIDs := make([]uint, 0)
+	for i := range liveProjects {
+		if projectRoleRank(roleOf(&liveProjects[i])) >= projectRoleRank(model.ProjectRoleEditor) {
+			projectNames[liveProjects[i].ID] = liveProjects[i].Name
+			projectIDs = append(projectIDs, liveProjects[i].ID)
+		}
+	}
+
+	if len(projectIDs) > 0 {
+		var sections []model.Section
+		err = a.DB.Unscoped().Where("project_id IN ?", projectIDs).Find(&sections).Error
+		if err != nil {
+			http.Error(w, "Failed to fetch trash", http.StatusInternalServerError)
+			return
+		}
+
+		liveSections := make(map[uint]*model.Section)
+		liveSectionIDs := make([]uint, 0)
+		for i := range sections {
+			section := &sections[i]
+			if !section.DeletedAt.Valid {
+				liveSections[section.ID] = section
+				liveSectionIDs = append(liveSectionIDs, section.ID)
+				continue
+			}
+			items = append(items, trashItemView{
+				Type:      trashSection,
+				ID:        section.ID,
+				Name:      section.Title,
+				Project:   projectNames[section.ProjectID],
+				DeletedAt: section.DeletedAt.Time,
+				PurgeAt:   section.DeletedAt.Time.Add(retention),
+			})
+		}
+
+		if len(liveSectionIDs) > 0 {
+			var contents []model.Content
+			err = a.DB.Unscoped().Where("section_id IN ? AND deleted_at IS NOT NULL", liveSectionIDs).Find(&contents).Error
+			if err != nil {
+				http.Error(w, "Failed to fetch trash", http.StatusInternalServerError)
+				return
+			}
+			for _, content := range contents {
+				section := liveSections[content.SectionID]
+				items = append(items, trashItemView{
+					Type:      trashContent,
+					ID:        content.ID,
+					Name:      content.Type,
+					Project:   projectNames[section.ProjectID],
+					Section:   section.Title,
+					DeletedAt: content.DeletedAt.Time,
+					PurgeAt:   content.DeletedAt.Time.Add(retention),
+				})
+			}
+		}
+	}
+
+	json.NewEncoder(w).Encode(items)
+}
+
+// Удалённый проект или шаблон из пути запроса: /trash/projects/{id}.
+// Восстанавливать и удалять окончательно могут только владельцы.
+func (a *API) requestTrashedProject(r *http.Request) (*model.Project, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return nil, errNotFound
+	}
+
+	var project model.Project
+	err = a.accessibleProjects(currentUser(r)).Unscoped().
+		Where("projects.id = ? AND projects.deleted_at IS NOT NULL", id).First(&project).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := a.projectRole(currentUser(r), &project)
+	if err != nil {
+		return nil, err
+	}
+	if role != model.ProjectRoleOwner {
+		return nil, errForbidden
+	}
+	return &project, nil
+}
+
+// Удалённый раздел из пути запроса: /trash/sections/{id}.
+// Проект раздела должен существовать, а пользователь - быть его редактором.
+func (a *API) requestTrashedSection(r *http.Request) (*model.Section, error) {
+	var section model.Section
+	err := a.DB.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", mux.Vars(r)["id"]).First(&section).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := a.requireProjectRole(r, section.ProjectID, model.ProjectRoleEditor); err != nil {
+		return nil, err
+	}
+	return &section, nil
+}
+
+// Удалённое содержимое из пути запроса: /trash/contents/{id}
+func (a *API) requestTrashedContent(r *http.Request) (*model.Content, error) {
+	var content model.Content
+	err := a.DB.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", mux.Vars(r)["id"]).First(&content).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var section model.Section
+	err = a.DB.First(&section, content.SectionID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := a.requireProjectRole(r, section.ProjectID, model.ProjectRoleEditor); err != nil {
+		return nil, err
+	}
+	return &content, nil
+}
+
+// Проверка роли пользователя запроса в существующем проекте
+func (a *API) requireProjectRole(r *http.Request, projectID uint, minRole string) (*model.Project, error) {
+	var project model.Project
+	err := a.accessibleProjects(currentUser(r)).Where("projects.id = ?", projectID).First(&project).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := a.projectRole(currentUser(r), &project)
+	if err != nil {
+		return nil, err
+	}
+	if projectRoleRank(role) < projectRoleRank(minRole) {
+		return nil, errForbidden
+	}
+	return &project, nil
+}
+
+// Восстановление проекта вместе с объектами, удалёнными одновременно с ним
+func (a *API) restoreProjectHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	project, err := a.requestTrashedProject(r)
+	if err != nil {
+		writeResolveError(w, err, "Project not found in trash")
+		return
+	}
+
+	deletedAt := project.DeletedAt.Time
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		tx = tx.Unscoped().Session(&gorm.Session{})
+
+		sections := tx.Model(&model.Section{}).Select("id").Where("project_id = ?", project.ID)
+		err := tx.Model(&model.Content{}).Where("section_id IN (?) AND deleted_at = ?", sections, deletedAt).
+			Update("deleted_at", nil).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&model.Section{}).Where("project_id = ? AND deleted_at = ?", project.ID, deletedAt).
+			Update("deleted_at", nil).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(project).Update("deleted_at", nil).Error
+	})
+	if err != nil {
+		http.Error(w, "Failed to restore project", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditProjectRestored, TargetType: auditTargetProject, TargetID: project.ID})
+
+	response := map[string]string{
+		"message": "Project restored",
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Восстановление раздела вместе с содержимым, удалённым одновременно с ним
+func (a *API) restoreSectionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	section, err := a.requestTrashedSection(r)
+	if err != nil {
+		writeResolveError(w, err, "Section not found in trash")
+		return
+	}
+
+	deletedAt := section.DeletedAt.Time
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		tx = tx.Unscoped().Session(&gorm.Session{})
+
+		err := tx.Model(&model.Content{}).Where("section_id = ? AND deleted_at = ?", section.ID, deletedAt).
+			Update("deleted_at", nil).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(section).Update("deleted_at", nil).Error
+	})
+	if err != nil {
+		http.Error(w, "Failed to restore section", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditSectionRestored, TargetType: auditTargetSection, TargetID: section.ID})
+
+	response := map[string]string{
+		"message": "Section restored",
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Восстановление содержимого
+func (a *API) restoreContentHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	content, err := a.requestTrashedContent(r)
+	if err != nil {
+		writeResolveError(w, err, "Content not found in trash")
+		return
+	}
+
+	err = a.DB.Unscoped().Model(content).Update("deleted_at", nil).Error
+	if err != nil {
+		http.Error(w, "Failed to restore content", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditContentRestored, TargetType: auditTargetContent, TargetID: content.ID})
+
+	response := map[string]string{
+		"message": "Content restored",
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Окончательное удаление проектов из подзапроса со всеми разделами,
+// содержимым, участниками, приглашениями, публичными ссылками и снимками.
+// tx должен быть Unscoped.
+func purgeProjectsTx(tx *gorm.DB, projects interface{}) error {
+	sections := tx.Model(&model.Section{}).Select("id").Where("project_id IN (?)", projects)
+
+	steps := []struct {
+		model interface{}
+		where string
+		arg   interface{}
+	}{
+		{&model.Content{}, "section_id IN (?)", sections},
+		{&model.Section{}, "project_id IN (?)", projects},
+		{&model.ProjectMember{}, "project_id IN (?)", projects},
+		{&model.ProjectInvitation{}, "project_id IN (?)", projects},
+		{&model.ShareLink{}, "project_id IN (?)", projects},
+		{&model.ProjectTag{}, "project_id IN (?)", projects},
+		{&model.ProjectSnapshot{}, "project_id IN (?)", projects},
+		{&model.Project{}, "id IN (?)", projects},
+	}
+
+	// Прежние адреса проектов и их разделов удаляются первыми:
+	// подзапрос projects перестаёт находить проекты после их удаления
+	err := tx.Where("(kind = ? AND target_id IN (?)) OR (kind = ? AND parent_id IN (?))",
+		model.SlugKindProject, projects, model.SlugKindSection, projects).Delete(&model.SlugAlias{}).Error
+	if err != nil {
+		return err
+	}
+
+	for _, step := range steps {
+		if err := tx.Where(step.where, step.arg).Delete(step.model).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Окончательное удаление разделов из подзапроса вместе с содержимым
+func purgeSectionsTx(tx *gorm.DB, sections interface{}) error {
+	err := tx.Where("section_id IN (?)", sections).Delete(&model.Content{}).Error
+	if err != nil {
+		return err
+	}
+	err = tx.Where("kind = ? AND target_id IN (?)", model.SlugKindSection, sections).Delete(&model.SlugAlias{}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Where("id IN (?)", sections).Delete(&model.Section{}).Error
+}
+
+// Окончательное удаление проекта из корзины
+func (a *API) purgeProjectHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	project, err := a.requestTrashedProject(r)
+	if err != nil {
+		writeResolveError(w, err, "Project not found in trash")
+		return
+	}
+
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		tx = tx.Unscoped().Session(&gorm.Session{})
+		return purgeProjectsTx(tx, tx.Model(&model.Project{}).Select("id").Where("id = ?", project.ID))
+	})
+	if err != nil {
+		http.Error(w, "Failed to purge project", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditProjectPurged, TargetType: auditTargetProject, TargetID: project.ID, Before: *project})
+
+	response := map[string]string{
+		"message": "Project deleted permanently",
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Окончательное удаление раздела из корзины
+func (a *API) purgeSectionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	section, err := a.requestTrashedSection(r)
+	if err != nil {
+		writeResolveError(w, err, "Section not found in trash")
+		return
+	}
+
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		tx = tx.Unscoped().Session(&gorm.Session{})
+		return purgeSectionsTx(tx, tx.Model(&model.Section{}).Select("id").Where("id = ?", section.ID))
+	})
+	if err != nil {
+		http.Error(w, "Failed to purge section", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditSectionPurged, TargetType: auditTargetSection, TargetID: section.ID, Before: *section})
+
+	response := map[string]string{
+		"message": "Section deleted permanently",
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Окончательное удаление содержимого из корзины
+func (a *API) purgeContentHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	content, err := a.requestTrashedContent(r)
+	if err != nil {
+		writeResolveError(w, err, "Content not found in trash")
+		return
+	}
+
+	err = a.DB.Unscoped().Delete(content).Error
+	if err != nil {
+		http.Error(w, "Failed to purge content", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{Action: auditContentPurged, TargetType: auditTargetContent, TargetID: content.ID, Before: *content})
+
+	response := map[string]string{
+		"message": "Content deleted permanently",
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Окончательное удаление объектов, пролежавших в корзине дольше срока хранения
+func (a *API) purgeTrash() error {
+	cutoff := time.Now().Add(-time.Duration(a.Config.Trash.Retention))
+
+	return a.DB.Transaction(func(tx *gorm.DB) error {
+		tx = tx.Unscoped().Session(&gorm.Session{})
+
+		projects := tx.Model(&model.Project{}).Select("id").Where("deleted_at < ?", cutoff)
+		if err := purgeProjectsTx(tx, projects); err != nil {
+			return err
+		}
+		sections := tx.Model(&model.Section{}).Select("id").Where("deleted_at < ?", cutoff)
+		if err := purgeSectionsTx(tx, sections); err != nil {
+			return err
+		}
+		return tx.Where("deleted_at < ?", cutoff).Delete(&model.Content{}).Error
+	})
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+	"github.com/roGal1k/golang-beginner/internal/mail"
+)
+
+var errInvalidUserToken = errors.New("invalid or expired token")
+
+// Выпуск одноразового токена; ранее выданные неиспользованные токены
+// того же назначения аннулируются
+func (a *API) createUserToken(userID uint, purpose string, ttl time.Duration) (string, error) {
+	raw, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+			Update("used_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&model.UserToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: hashToken(raw),
+			ExpiresAt: time.Now().Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// Погашение одноразового токена внутри транзакции.
+// Повторное использование и истёкшие токены отклоняются.
+func consumeUserToken(tx *gorm.DB, raw, purpose string) (*model.UserToken, error) {
+	var token model.UserToken
+	err := tx.Where("token_hash = ? AND purpose = ?", hashToken(raw), purpose).First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errInvalidUserToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+		return nil, errInvalidUserToken
+	}
+
+	// Условное обновление защищает от одновременного погашения
+	result := tx.Model(&model.UserToken{}).
+		Where("id = ? AND used_at IS NULL", token.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errInvalidUserToken
+	}
+	return &token, nil
+}
+
+// Фоновая отправка письма: ответ клиенту не зависит от скорости доставки
+func (a *API) sendMail(msg mail.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		if err := a.Mailer.Send(ctx, msg); err != nil {
+			log.Printf("failed to send mail %q to %s: %v", msg.Subject, msg.To, err)
+		}
+	}()
+}
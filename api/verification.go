@@ -0,0 +1,137 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/roGal1k/golang-beginner/assets/model"
+	"github.com/roGal1k/golang-beginner/internal/mail"
+)
+
+// Действия, которые политика может запретить пользователям с неподтверждённым email
+const (
+	actionCreateProject = "create_project"
+	actionShare         = "share"   // Приглашение участников в проект
+	actionPublish       = "publish" // Публичные ссылки на проект
+)
+
+// Отправка письма со ссылкой для подтверждения email
+func (a *API) sendEmailVerification(user *model.User) error {
+	ttl := time.Duration(a.Config.Auth.EmailVerificationTTL)
+	token, err := a.createUserToken(user.ID, model.TokenPurposeEmailVerification, ttl)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", a.Config.PublicURL, url.QueryEscape(token))
+	a.sendMail(mail.Message{
+		To:      user.Email,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf("Hello, %s!\n\nTo confirm your email address, open the link below:\n%s\n\n"+
+			"The link is valid for %s.\n", user.Username, link, ttl),
+	})
+	return nil
+}
+
+// Запрещено ли действие пользователю до подтверждения email
+func (a *API) verificationRequired(user *model.User, action string) bool {
+	if user.EmailVerifiedAt != nil {
+		return false
+	}
+	for _, restricted := range a.Config.Auth.UnverifiedRestrictions {
+		if restricted == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Ограничение маршрута для пользователей с неподтверждённым email
+func (a *API) requireVerified(action string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.verificationRequired(currentUser(r), action) {
+			w.Header().Set("Content-Type", "application/json")
+			http.Error(w, "Email verification required", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Подтверждение email по токену из письма
+func (a *API) verifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		Token string `json:"token"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if request.Token == "" {
+		http.Error(w, "Token is required", http.StatusBadRequest)
+		return
+	}
+
+	var userID uint
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, request.Token, model.TokenPurposeEmailVerification)
+		if err != nil {
+			return err
+		}
+		userID = token.UserID
+		return tx.Model(&model.User{}).Where("id = ?", token.UserID).
+			Update("email_verified_at", time.Now()).Error
+	})
+	if errors.Is(err, errInvalidUserToken) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to verify email", http.StatusInternalServerError)
+		return
+	}
+
+	a.audit(r, auditEntry{ActorID: &userID, Action: auditEmailVerified, TargetType: auditTargetUser, TargetID: userID})
+
+	response := map[string]string{
+		"message": "Email verified",
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Повторная отправка письма для подтверждения email
+func (a *API) resendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user := currentUser(r)
+	if user.Email == "" {
+		http.Error(w, "Email is not set", http.StatusBadRequest)
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		http.Error(w, "Email is already verified", http.StatusConflict)
+		return
+	}
+
+	err := a.sendEmailVerification(user)
+	if err != nil {
+		http.Error(w, "Failed to create verification token", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	response := map[string]string{
+		"message": "Verification email sent",
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -1,22 +1,308 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Роли пользователей
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
 
 // Модель пользователя
 type User struct {
 	gorm.Model
 	Username string `gorm:"unique"`
 	Password string
-	Token    string
-	Projects []Project // Связь с проектами пользователя
+	Role     string `gorm:"default:user"`
+	// Момент блокировки администратором; nil - учётная запись активна
+	DisabledAt *time.Time
+	// Пароль должен быть сменён через восстановление перед следующим входом
+	PasswordResetRequired bool
+	// Момент окончательного удаления учётной записи по запросу пользователя
+	DeletionScheduledAt *time.Time
+	Email               string `gorm:"index"` // Адрес для восстановления доступа
+	// Момент подтверждения email; nil - адрес не подтверждён
+	EmailVerifiedAt *time.Time
+	// Двухфакторная аутентификация (TOTP)
+	TOTPSecret        string     // Подтверждённый секрет
+	TOTPPendingSecret string     // Секрет, ожидающий подтверждения первым кодом
+	TOTPEnabledAt     *time.Time // nil - двухфакторная аутентификация выключена
+	TOTPLastCounter   int64      // Последний принятый шаг времени (защита от повтора кода)
+	// Версия токенов: увеличивается при выходе со всех устройств,
+	// токены с меньшей версией считаются недействительными
+	TokenVersion uint
+	Projects     []Project // Связь с проектами пользователя
+}
+
+// Отозванный токен (чёрный список по jti)
+type RevokedToken struct {
+	gorm.Model
+	JTI       string `gorm:"uniqueIndex"`
+	UserID    uint
+	ExpiresAt time.Time `gorm:"index"` // После этого момента запись можно удалить
+}
+
+// Назначения одноразовых токенов пользователя
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+)
+
+// Одноразовый токен пользователя, отправляемый по почте (хранится только хеш)
+type UserToken struct {
+	gorm.Model
+	UserID    uint      `gorm:"index"`
+	Purpose   string    `gorm:"index"`
+	TokenHash string    `gorm:"uniqueIndex"`
+	ExpiresAt time.Time `gorm:"index"`
+	UsedAt    *time.Time
+}
+
+// Резервный код для входа без приложения-аутентификатора (хранится только хеш)
+type RecoveryCode struct {
+	gorm.Model
+	UserID   uint `gorm:"index"`
+	CodeHash string
+	UsedAt   *time.Time
+}
+
+// Незавершённый вход: пароль проверен, ожидается второй фактор
+type LoginChallenge struct {
+	gorm.Model
+	UserID    uint
+	TokenHash string    `gorm:"uniqueIndex"`
+	ExpiresAt time.Time `gorm:"index"`
+	UsedAt    *time.Time
+	Attempts  int
+}
+
+// Персональный токен доступа для автоматизации (хранится только хеш)
+type PersonalAccessToken struct {
+	gorm.Model
+	UserID     uint `gorm:"index"`
+	Name       string
+	Prefix     string // Начало токена для отображения в списке
+	TokenHash  string `gorm:"uniqueIndex"`
+	Scopes     string // Права доступа через пробел
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+// Счётчик неудачных попыток по имени пользователя или IP-адресу
+type LoginThrottle struct {
+	gorm.Model
+	Key           string `gorm:"uniqueIndex"` // Например, "user:alice" или "ip:10.0.0.1"
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+// Учётная запись пользователя у внешнего провайдера OpenID Connect
+type UserIdentity struct {
+	gorm.Model
+	UserID   uint   `gorm:"index"`
+	Provider string `gorm:"uniqueIndex:idx_identity_provider_subject"`
+	Subject  string `gorm:"uniqueIndex:idx_identity_provider_subject"`
+	Email    string
+}
+
+// Начатый вход через OpenID Connect: state, nonce и PKCE verifier
+type OIDCLoginState struct {
+	gorm.Model
+	Provider     string
+	StateHash    string `gorm:"uniqueIndex"`
+	Nonce        string
+	CodeVerifier string
+	LinkUserID   *uint     // Привязка провайдера к уже вошедшему пользователю
+	ExpiresAt    time.Time `gorm:"index"`
+}
+
+// Запись журнала аудита. Записи только добавляются, поэтому
+// у модели нет UpdatedAt и DeletedAt.
+type AuditEvent struct {
+	ID         uint      `gorm:"primarykey"`
+	CreatedAt  time.Time `gorm:"index"`
+	ActorID    *uint     `gorm:"index"` // nil - действие без аутентифицированного пользователя
+	Action     string    `gorm:"index"`
+	TargetType string    `gorm:"index:idx_audit_target"`
+	TargetID   uint      `gorm:"index:idx_audit_target"`
+	IP         string
+	UserAgent  string
+	Changes    string // JSON: поле -> {"before": ..., "after": ...}
+	Metadata   string // JSON с дополнительными сведениями
+}
+
+// Сессия входа, объединяющая цепочку refresh-токенов
+type Session struct {
+	gorm.Model
+	UserID     uint `gorm:"index"`
+	UserAgent  string
+	IP         string
+	LastSeenAt time.Time
+	RevokedAt  *time.Time
+}
+
+// Refresh-токен сессии (хранится только хеш)
+type RefreshToken struct {
+	gorm.Model
+	SessionID uint       `gorm:"index"`
+	TokenHash string     `gorm:"uniqueIndex"`
+	ExpiresAt time.Time  `gorm:"index"`
+	UsedAt    *time.Time // Момент ротации; повторное использование означает утечку
 }
 
+// Видимость проекта организации
+const (
+	VisibilityPrivate      = "private"      // Только участники проекта и администраторы организации
+	VisibilityOrganization = "organization" // Все участники организации (на чтение)
+)
+
 // Модель проекта
 type Project struct {
 	gorm.Model
-	UserID   uint
-	Name     string
-	Sections []*Section `json:"sections"`
+	UserID uint // Владелец личного проекта; у проекта организации - создатель
+	// Организация-владелец; nil - личный проект пользователя
+	OrganizationID *uint `gorm:"index"`
+	Name           string
+	Slug           string     `gorm:"index"`           // Адрес, уникальный среди проектов владельца
+	IsTemplate     bool       `gorm:"index"`           // Шаблон для создания проектов
+	Visibility     string     `gorm:"default:private"` // private или organization
+	Sections       []*Section `json:"sections"`
+	Tags           []string   `gorm:"-" json:"tags,omitempty"` // Метки из ProjectTag
+}
+
+// Метка проекта для поиска и фильтрации списка
+type ProjectTag struct {
+	gorm.Model
+	ProjectID uint   `gorm:"uniqueIndex:idx_project_tag"`
+	Name      string `gorm:"uniqueIndex:idx_project_tag;index"`
+}
+
+// Роли участников организации
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+// Организация, которой могут принадлежать проекты и шаблоны
+type Organization struct {
+	gorm.Model
+	Name string `gorm:"uniqueIndex"`
+	// Видимость новых проектов организации по умолчанию
+	DefaultVisibility string `gorm:"default:private"`
+	// Создавать проекты могут только владельцы и администраторы
+	RestrictProjectCreation bool
+}
+
+// Участник организации
+type OrganizationMember struct {
+	gorm.Model
+	OrganizationID uint   `gorm:"uniqueIndex:idx_organization_member"`
+	UserID         uint   `gorm:"uniqueIndex:idx_organization_member;index"`
+	Role           string // owner, admin или member
+}
+
+// Роли участников проекта
+const (
+	ProjectRoleOwner  = "owner"
+	ProjectRoleEditor = "editor"
+	ProjectRoleViewer = "viewer"
+)
+
+// Участник проекта. Владелец личного проекта (Project.UserID) всегда
+// имеет роль owner и в этой таблице не хранится.
+type ProjectMember struct {
+	gorm.Model
+	ProjectID uint   `gorm:"uniqueIndex:idx_project_member"`
+	UserID    uint   `gorm:"uniqueIndex:idx_project_member;index"`
+	Role      string // owner, editor или viewer
+}
+
+// Статусы приглашения в проект
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+	InvitationRevoked  = "revoked"
+)
+
+// Приглашение в проект по имени пользователя или email
+type ProjectInvitation struct {
+	gorm.Model
+	ProjectID uint `gorm:"index"`
+	InviterID uint
+	InviteeID *uint `gorm:"index"` // nil - приглашение на адрес без учётной записи
+	Email     string
+	Role      string
+	TokenHash string `gorm:"uniqueIndex"` // Токен из письма с приглашением
+	Status    string `gorm:"default:pending"`
+	ExpiresAt time.Time
+}
+
+// Публичная ссылка на просмотр проекта без учётной записи (хранится только хеш)
+type ShareLink struct {
+	gorm.Model
+	ProjectID    uint `gorm:"index"`
+	CreatedByID  uint
+	Prefix       string // Начало токена для отображения в списке
+	TokenHash    string `gorm:"uniqueIndex"`
+	PasswordHash string // Пусто - ссылка без пароля
+	ExpiresAt    *time.Time
+	RevokedAt    *time.Time
+	ViewCount    int64
+	LastViewedAt *time.Time
+}
+
+// Виды объектов с адресами
+const (
+	SlugKindProject = "project"
+	SlugKindSection = "section"
+)
+
+// Прежний адрес переименованного проекта или раздела: запросы
+// по нему перенаправляются на текущий адрес
+type SlugAlias struct {
+	gorm.Model
+	Kind     string `gorm:"index:idx_slug_alias"`
+	ParentID uint   `gorm:"index:idx_slug_alias"` // Проект раздела; 0 для проектов
+	Slug     string `gorm:"index:idx_slug_alias"`
+	TargetID uint   `gorm:"index"`
+}
+
+// Причины создания снимка проекта
+const (
+	SnapshotManual    = "manual"    // Снимок с названием, созданный пользователем
+	SnapshotScheduled = "scheduled" // Периодический снимок изменившегося проекта
+	SnapshotRollback  = "rollback"  // Состояние перед откатом к другому снимку
+)
+
+// Снимок разделов и содержимого проекта
+type ProjectSnapshot struct {
+	gorm.Model
+	ProjectID    uint  `gorm:"index"`
+	CreatedByID  *uint // nil - автоматический снимок
+	Name         string
+	Reason       string
+	Checksum     string // SHA-256 дерева: неизменившийся проект повторно не сохраняется
+	SectionCount int
+	ContentCount int
+	Tree         string // Разделы с содержимым в JSON
+}
+
+// Загруженный пользователем файл
+type Media struct {
+	gorm.Model
+	UserID      uint   `gorm:"index"`
+	Key         string `gorm:"uniqueIndex"` // Ключ объекта в хранилище
+	Filename    string
+	ContentType string
+	Size        int64
 }
 
 // Модель раздела проекта
@@ -24,6 +310,7 @@ type Section struct {
 	gorm.Model
 	ProjectID uint
 	Title     string
+	Slug      string `gorm:"index"` // Адрес, уникальный внутри проекта
 	//	Image     string
 	Contents []Content // Связь с содержимым раздела
 }
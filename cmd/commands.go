@@ -0,0 +1,95 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/roGal1k/golang-beginner/api"
+	"github.com/roGal1k/golang-beginner/assets/model"
+)
+
+const usage = `usage:
+  main export -project <id> [-o <file.zip>]
+  main import -user <username> [-organization <name>] <file.zip>`
+
+// Выполнение команды обслуживания
+func runCommand(a *api.API, args []string) error {
+	switch args[0] {
+	case "export":
+		return exportCommand(a, args[1:])
+	case "import":
+		return importCommand(a, args[1:])
+	default:
+		return errors.New(usage)
+	}
+}
+
+// Экспорт проекта в zip-архив (по умолчанию - в стандартный вывод)
+func exportCommand(a *api.API, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	projectID := flags.Uint("project", 0, "project id")
+	output := flags.String("o", "", "output file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *projectID == 0 {
+		return errors.New(usage)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	return a.ExportProject(context.Background(), w, *projectID)
+}
+
+// Импорт проекта из zip-архива в проекты пользователя или организации
+func importCommand(a *api.API, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	username := flags.String("user", "", "owner username")
+	organization := flags.String("organization", "", "organization name")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *username == "" || flags.NArg() != 1 {
+		return errors.New(usage)
+	}
+
+	var user model.User
+	err := a.DB.Where("username = ?", *username).First(&user).Error
+	if err != nil {
+		return fmt.Errorf("user %q: %w", *username, err)
+	}
+	var org *model.Organization
+	if *organization != "" {
+		org = &model.Organization{}
+		err = a.DB.Where("name = ?", *organization).First(org).Error
+		if err != nil {
+			return fmt.Errorf("organization %q: %w", *organization, err)
+		}
+	}
+
+	archive, err := zip.OpenReader(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	project, err := a.ImportProject(context.Background(), &archive.Reader, &user, org)
+	if err != nil {
+		return err
+	}
+	log.Printf("imported project %d %q (%s)", project.ID, project.Name, project.Slug)
+	return nil
+}
@@ -2,9 +2,14 @@ package main
 
 import (
 	"log"
+	"os"
 
 	"github.com/roGal1k/golang-beginner/api"
+	"github.com/roGal1k/golang-beginner/internal/config"
 	db "github.com/roGal1k/golang-beginner/internal/database"
+	"github.com/roGal1k/golang-beginner/internal/mail"
+	"github.com/roGal1k/golang-beginner/internal/passwords"
+	"github.com/roGal1k/golang-beginner/internal/storage"
 	"gorm.io/gorm"
 )
 
@@ -13,6 +18,36 @@ type API struct {
 }
 
 func main() {
+	// Загрузка настроек
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Загрузка ключей подписи токенов
+	keys, err := api.LoadKeySet(cfg.Auth)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Политика паролей
+	passwordPolicy, err := passwords.NewPolicy(cfg.Auth.PasswordPolicy)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Настройка отправки писем
+	mailer, err := mail.New(cfg.Mail)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Хранилище загруженных файлов
+	files, err := storage.New(cfg.Storage)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Создание экземпляра базы данных
 	database, err := db.InitDB()
 	if err != nil {
@@ -25,9 +60,36 @@ func main() {
 		log.Fatal(err)
 	}
 
+	// Назначение администраторов из настроек
+	err = db.EnsureAdmins(database, cfg.Auth.Admins)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Создание экземпляра API с передачей базы данных
 	apiInstance := &api.API{
-		DB: database,
+		DB:     database,
+		Config: cfg,
+		Keys:   keys,
+		Mailer: mailer,
+
+		PasswordPolicy: passwordPolicy,
+		Storage:        files,
+	}
+
+	// Заполнение адресов проектов и разделов, созданных до их появления
+	err = apiInstance.BackfillSlugs()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Команды обслуживания вместо запуска сервера
+	if len(os.Args) > 1 {
+		err = runCommand(apiInstance, os.Args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
 	// Запуск сервера
@@ -0,0 +1,35 @@
+// Локальный провайдер OpenID Connect для разработки:
+//
+//	go run ./cmd/mockoidc -addr :9000 -client-id local
+//
+// В config.json провайдер подключается с issuer http://localhost:9000.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/roGal1k/golang-beginner/internal/oidc/mockissuer"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "адрес для прослушивания")
+	issuer := flag.String("issuer", "http://localhost:9000", "issuer провайдера")
+	clientID := flag.String("client-id", "local", "client_id приложения")
+	username := flag.String("user", "alice", "пользователь по умолчанию")
+	flag.Parse()
+
+	server, err := mockissuer.New(*issuer, *clientID, mockissuer.User{
+		Subject:       "mock-" + *username,
+		Email:         *username + "@example.test",
+		EmailVerified: true,
+		Username:      *username,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Mock OIDC issuer %s started on %s", *issuer, *addr)
+	log.Fatal(http.ListenAndServe(*addr, server.Handler()))
+}
@@ -0,0 +1,204 @@
+// Модуль config: настройки приложения
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// Путь к файлу настроек по умолчанию; переопределяется переменной CONFIG_PATH
+const defaultPath = "config.json"
+
+type Config struct {
+	PublicURL  string               `json:"public_url"`  // Адрес приложения для ссылок в письмах
+	TrustProxy bool                 `json:"trust_proxy"` // Брать адрес клиента из X-Forwarded-For
+	Auth       AuthConfig           `json:"auth"`
+	Mail       MailConfig           `json:"mail"`
+	Storage    StorageConfig        `json:"storage"`
+	Trash      TrashConfig          `json:"trash"`
+	Snapshots  SnapshotConfig       `json:"snapshots"`
+	OIDC       []OIDCProviderConfig `json:"oidc"`
+}
+
+// Настройки выдачи и проверки токенов
+type AuthConfig struct {
+	Issuer               string   `json:"issuer"`
+	AccessTokenTTL       Duration `json:"access_token_ttl"`
+	RefreshTokenTTL      Duration `json:"refresh_token_ttl"`
+	PasswordResetTTL     Duration `json:"password_reset_ttl"`
+	EmailVerificationTTL Duration `json:"email_verification_ttl"`
+	AccountDeletionGrace Duration `json:"account_deletion_grace"` // Срок до окончательного удаления учётной записи
+	TOTPIssuer           string   `json:"totp_issuer"`            // Название сервиса в приложении-аутентификаторе
+	// Действия, запрещённые до подтверждения email: create_project, share, publish
+	UnverifiedRestrictions []string             `json:"unverified_restrictions"`
+	Admins                 []string             `json:"admins"` // Пользователи, назначаемые администраторами при запуске
+	Throttle               ThrottleConfig       `json:"throttle"`
+	PasswordPolicy         PasswordPolicyConfig `json:"password_policy"`
+	ActiveKey              string               `json:"active_key"` // kid ключа, которым подписываются новые токены
+	Keys                   []KeyConfig          `json:"keys"`
+}
+
+// Защита от перебора паролей
+type ThrottleConfig struct {
+	UserFreeAttempts     int      `json:"user_free_attempts"`     // Неудачных попыток на имя пользователя без задержки
+	IPFreeAttempts       int      `json:"ip_free_attempts"`       // Неудачных попыток с одного адреса без задержки
+	RegisterFreeAttempts int      `json:"register_free_attempts"` // Регистраций с одного адреса без задержки
+	BaseDelay            Duration `json:"base_delay"`             // Первая задержка, далее удваивается
+	MaxDelay             Duration `json:"max_delay"`              // Максимальная длительность блокировки
+	Window               Duration `json:"window"`                 // Через сколько без ошибок счётчик сбрасывается
+}
+
+// Требования к паролям
+type PasswordPolicyConfig struct {
+	MinLength    int    `json:"min_length"`
+	DenylistFile string `json:"denylist_file"` // Файл с запрещёнными паролями, по одному на строку
+}
+
+// Ключ подписи. Ключи без закрытой части используются только для проверки
+// (например, выведенные из оборота во время ротации).
+type KeyConfig struct {
+	ID             string `json:"kid"`
+	Algorithm      string `json:"alg"`                        // HS256, RS256 или EdDSA
+	Secret         string `json:"secret,omitempty"`           // Секрет HS256
+	SecretEnv      string `json:"secret_env,omitempty"`       // Переменная окружения с секретом HS256
+	PrivateKeyFile string `json:"private_key_file,omitempty"` // PEM с закрытым ключом
+	PublicKeyFile  string `json:"public_key_file,omitempty"`  // PEM с открытым ключом
+}
+
+// Настройки отправки писем
+type MailConfig struct {
+	Driver          string `json:"driver"` // smtp, file или memory
+	From            string `json:"from"`
+	SMTPHost        string `json:"smtp_host"`
+	SMTPPort        int    `json:"smtp_port"`
+	SMTPUsername    string `json:"smtp_username"`
+	SMTPPassword    string `json:"smtp_password"`
+	SMTPPasswordEnv string `json:"smtp_password_env"` // Переменная окружения с паролем SMTP
+	OutboxDir       string `json:"outbox_dir"`        // Каталог для драйвера file
+}
+
+// Настройки хранения загруженных файлов
+type StorageConfig struct {
+	Driver  string `json:"driver"`   // local или s3
+	Dir     string `json:"dir"`      // Каталог для драйвера local
+	BaseURL string `json:"base_url"` // Адрес, по которому доступны файлы
+	Bucket  string `json:"bucket"`
+	Region  string `json:"region"`
+	ACL     string `json:"acl"` // Например, public-read
+}
+
+// Настройки корзины удалённых проектов, разделов и содержимого
+type TrashConfig struct {
+	Retention Duration `json:"retention"` // Через сколько удалённые объекты удаляются окончательно
+}
+
+// Настройки автоматических снимков проектов
+type SnapshotConfig struct {
+	Interval Duration `json:"interval"` // Период снимков изменившихся проектов; 0 - отключены
+	Keep     int      `json:"keep"`     // Сколько автоматических снимков хранить для проекта; 0 - все
+}
+
+// Провайдер входа OpenID Connect
+type OIDCProviderConfig struct {
+	Name            string   `json:"name"` // Используется в адресах /oidc/{name}/...
+	Issuer          string   `json:"issuer"`
+	ClientID        string   `json:"client_id"`
+	ClientSecret    string   `json:"client_secret"`
+	ClientSecretEnv string   `json:"client_secret_env"` // Переменная окружения с client_secret
+	RedirectURL     string   `json:"redirect_url"`
+	Scopes          []string `json:"scopes"`
+	AllowSignup     bool     `json:"allow_signup"`  // Создавать учётную запись при первом входе
+	LinkByEmail     bool     `json:"link_by_email"` // Привязывать к учётной записи с тем же подтверждённым email
+}
+
+// Длительность в формате time.ParseDuration ("15m", "720h")
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Настройки по умолчанию
+func Default() *Config {
+	return &Config{
+		PublicURL: "http://localhost:8080",
+		Auth: AuthConfig{
+			AccessTokenTTL:       Duration(15 * time.Minute),
+			RefreshTokenTTL:      Duration(30 * 24 * time.Hour),
+			PasswordResetTTL:     Duration(time.Hour),
+			EmailVerificationTTL: Duration(48 * time.Hour),
+			AccountDeletionGrace: Duration(14 * 24 * time.Hour),
+			TOTPIssuer:           "golang-beginner",
+			Throttle: ThrottleConfig{
+				UserFreeAttempts:     5,
+				IPFreeAttempts:       50,
+				RegisterFreeAttempts: 10,
+				BaseDelay:            Duration(time.Second),
+				MaxDelay:             Duration(15 * time.Minute),
+				Window:               Duration(time.Hour),
+			},
+			PasswordPolicy: PasswordPolicyConfig{
+				MinLength: 8,
+			},
+			UnverifiedRestrictions: []string{"share", "publish"},
+		},
+		Mail: MailConfig{
+			Driver:   "file",
+			From:     "no-reply@localhost",
+			SMTPPort: 587,
+		},
+		Storage: StorageConfig{
+			Driver:  "local",
+			Dir:     "uploads",
+			BaseURL: "http://localhost:8080/uploads",
+		},
+		Trash: TrashConfig{
+			Retention: Duration(30 * 24 * time.Hour),
+		},
+		Snapshots: SnapshotConfig{
+			Interval: Duration(24 * time.Hour),
+			Keep:     30,
+		},
+	}
+}
+
+// Загрузка настроек из файла. Отсутствующий файл не считается ошибкой:
+// в этом случае используются настройки по умолчанию.
+func Load() (*Config, error) {
+	path := os.Getenv("CONFIG_PATH")
+	if path == "" {
+		path = defaultPath
+	}
+
+	cfg := Default()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	return cfg, nil
+}
@@ -102,9 +102,63 @@ func AutoMigrate(db *gorm.DB) error {
 		&model.Project{},
 		&model.Section{},
 		&model.Content{},
+		&model.RevokedToken{},
+		&model.Session{},
+		&model.RefreshToken{},
+		&model.UserToken{},
+		&model.RecoveryCode{},
+		&model.LoginChallenge{},
+		&model.PersonalAccessToken{},
+		&model.LoginThrottle{},
+		&model.UserIdentity{},
+		&model.OIDCLoginState{},
+		&model.Media{},
+		&model.AuditEvent{},
+		&model.ProjectMember{},
+		&model.ProjectInvitation{},
+		&model.Organization{},
+		&model.OrganizationMember{},
+		&model.ShareLink{},
+		&model.SlugAlias{},
+		&model.ProjectTag{},
+		&model.ProjectSnapshot{},
 	)
 	if err != nil {
 		return err
 	}
+
+	// Журнал аудита только дополняется: изменение и удаление записей
+	// запрещены на уровне базы данных. Уникальность адресов задаётся
+	// частичными индексами, которые не выражаются тегами gorm.
+	statements := []string{
+		`CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'audit_events is append-only';
+		END;
+		$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events`,
+		`CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE ON audit_events
+		FOR EACH ROW EXECUTE PROCEDURE audit_events_append_only()`,
+		// Адреса уникальны среди проектов владельца (пользователя или организации)
+		// и среди разделов проекта; пустые адреса заполняются при запуске
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_projects_user_slug ON projects (user_id, is_template, slug)
+		WHERE organization_id IS NULL AND slug <> ''`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_projects_organization_slug ON projects (organization_id, is_template, slug)
+		WHERE organization_id IS NOT NULL AND slug <> ''`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_sections_project_slug ON sections (project_id, slug) WHERE slug <> ''`,
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// Назначение администраторами пользователей из настроек
+func EnsureAdmins(db *gorm.DB, usernames []string) error {
+	if len(usernames) == 0 {
+		return nil
+	}
+	return db.Model(&model.User{}).Where("username IN ?", usernames).Update("role", model.RoleAdmin).Error
+}
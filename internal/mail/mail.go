@@ -0,0 +1,35 @@
+// Модуль mail: отправка писем пользователям
+package mail
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/roGal1k/golang-beginner/internal/config"
+)
+
+// Письмо
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Способ доставки писем
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Создание отправителя по настройкам
+func New(cfg config.MailConfig) (Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		return NewSMTPMailer(cfg), nil
+	case "file":
+		return NewFileOutbox(cfg.From, cfg.OutboxDir)
+	case "", "memory":
+		return NewMemoryOutbox(), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
@@ -0,0 +1,100 @@
+// Модуль passwords: требования к паролям пользователей
+package passwords
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/roGal1k/golang-beginner/internal/config"
+)
+
+// bcrypt учитывает только первые 72 байта пароля
+const bcryptMaxBytes = 72
+
+var (
+	ErrTooLong      = errors.New("password is too long")
+	ErrCommon       = errors.New("password is too common")
+	ErrSameAsLogin  = errors.New("password must not match the username")
+	errInvalidLimit = errors.New("password policy: min_length must be positive")
+)
+
+// Ошибка слишком короткого пароля
+type TooShortError struct {
+	MinLength int
+}
+
+func (e *TooShortError) Error() string {
+	return fmt.Sprintf("password must be at least %d characters long", e.MinLength)
+}
+
+// Политика паролей
+type Policy struct {
+	minLength int
+	denylist  map[string]struct{}
+}
+
+// Создание политики по настройкам. Файл запрещённых паролей содержит
+// по одному паролю на строку; пустые строки и строки с # пропускаются.
+func NewPolicy(cfg config.PasswordPolicyConfig) (*Policy, error) {
+	if cfg.MinLength <= 0 {
+		return nil, errInvalidLimit
+	}
+
+	policy := &Policy{
+		minLength: cfg.MinLength,
+		denylist:  make(map[string]struct{}),
+	}
+	if cfg.DenylistFile == "" {
+		return policy, nil
+	}
+
+	file, err := os.Open(cfg.DenylistFile)
+	if err != nil {
+		return nil, fmt.Errorf("password denylist: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		policy.denylist[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("password denylist: %w", err)
+	}
+	return policy, nil
+}
+
+// Проверка пароля. Сравнение со списком и с именем пользователя
+// выполняется без учёта регистра.
+func (p *Policy) Check(password, username string) error {
+	if utf8.RuneCountInString(password) < p.minLength {
+		return &TooShortError{MinLength: p.minLength}
+	}
+	if len(password) > bcryptMaxBytes {
+		return ErrTooLong
+	}
+
+	lower := strings.ToLower(password)
+	if username != "" && lower == strings.ToLower(username) {
+		return ErrSameAsLogin
+	}
+	if _, ok := p.denylist[lower]; ok {
+		return ErrCommon
+	}
+	return nil
+}
+
+// Является ли ошибка нарушением политики паролей
+func IsPolicyError(err error) bool {
+	var tooShort *TooShortError
+	return errors.As(err, &tooShort) ||
+		errors.Is(err, ErrTooLong) || errors.Is(err, ErrCommon) || errors.Is(err, ErrSameAsLogin)
+}
@@ -0,0 +1,72 @@
+// Модуль slug: адреса объектов из названий с транслитерацией кириллицы
+package slug
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Максимальная длина адреса без суффикса
+const MaxLength = 60
+
+// Транслитерация русского алфавита (близко к ГОСТ 7.79-2000, вариант Б,
+// без диакритики и апострофов) и нескольких букв украинского и белорусского
+var transliteration = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ы': "y", 'э': "e", 'ю': "yu", 'я': "ya",
+	'і': "i", 'ї': "yi", 'є': "ye", 'ґ': "g", 'ў': "u",
+}
+
+// Адрес из названия: строчные латинские буквы, цифры и дефисы.
+// Пустая строка означает, что в названии нет подходящих символов.
+func Make(name string) string {
+	var b strings.Builder
+	pendingDash := false
+
+	write := func(s string) {
+		if s == "" {
+			return
+		}
+		if pendingDash && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		pendingDash = false
+		b.WriteString(s)
+	}
+
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			write(string(r))
+		case transliteration[r] != "":
+			write(transliteration[r])
+		case r == 'ъ' || r == 'ь' || r == '\'' || r == '’':
+			// Знаки, не дающие звука, не разделяют слово
+		default:
+			pendingDash = true
+		}
+	}
+
+	return truncate(b.String(), MaxLength)
+}
+
+// Вариант адреса с числовым суффиксом для разрешения совпадений: name-2, name-3...
+func WithSuffix(base string, n int) string {
+	suffix := "-" + strconv.Itoa(n)
+	return truncate(base, MaxLength-len(suffix)) + suffix
+}
+
+// Обрезка по границе слова, если она есть в пределах длины
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	s = s[:max]
+	if i := strings.LastIndexByte(s, '-'); i > 0 {
+		s = s[:i]
+	}
+	return strings.TrimRight(s, "-")
+}
@@ -0,0 +1,63 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"latin", "Hello World", "hello-world"},
+		{"digits", "Version 2.0", "version-2-0"},
+		{"cyrillic", "Привет, мир!", "privet-mir"},
+		{"multi-letter transliteration", "Щука и ёж", "shchuka-i-yozh"},
+		{"hard sign", "Объявление", "obyavlenie"},
+		{"soft sign", "Подъезд и сельдь", "podezd-i-seld"},
+		{"ukrainian", "Їжак", "yizhak"},
+		{"apostrophe", "Don't stop", "dont-stop"},
+		{"mixed scripts", "Go и Python", "go-i-python"},
+		{"punctuation collapses", "a___b...c", "a-b-c"},
+		{"dashes collapse", "  --Go!! -- lang--  ", "go-lang"},
+		{"empty", "", ""},
+		{"only punctuation", "!!! ---", ""},
+		{"unknown letters", "日本", ""},
+		{"truncated at word", strings.Repeat("word ", 20), strings.TrimSuffix(strings.Repeat("word-", 12), "-")},
+		{"truncated long word", strings.Repeat("a", 70), strings.Repeat("a", MaxLength)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Make(tt.in); got != tt.want {
+				t.Errorf("Make(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithSuffix(t *testing.T) {
+	tests := []struct {
+		name string
+		base string
+		n    int
+		want string
+	}{
+		{"short", "intro", 2, "intro-2"},
+		{"two digits", "intro", 10, "intro-10"},
+		{"long word", strings.Repeat("a", MaxLength), 2, strings.Repeat("a", MaxLength-2) + "-2"},
+		{"long words", strings.TrimSuffix(strings.Repeat("word-", 12), "-"), 10, strings.Repeat("word-", 11) + "10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithSuffix(tt.base, tt.n)
+			if got != tt.want {
+				t.Errorf("WithSuffix(%q, %d) = %q, want %q", tt.base, tt.n, got, tt.want)
+			}
+			if len(got) > MaxLength {
+				t.Errorf("WithSuffix(%q, %d) is %d bytes long, max %d", tt.base, tt.n, len(got), MaxLength)
+			}
+		})
+	}
+}
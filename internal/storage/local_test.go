@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorageCopy(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewLocalStorage(t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Put(ctx, "1/source.txt", strings.NewReader("hello"), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Copy(ctx, "1/source.txt", "2/copy.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := s.Open(ctx, "2/copy.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Fatalf("copy contains %q, want %q", data, "hello")
+	}
+
+	if err := s.Copy(ctx, "1/missing.txt", "2/missing.txt"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("copy of a missing object: got %v, want ErrNotFound", err)
+	}
+}
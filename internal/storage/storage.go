@@ -0,0 +1,37 @@
+// Модуль storage: хранение загруженных файлов
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/roGal1k/golang-beginner/internal/config"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// Хранилище файлов. Ключ - путь объекта внутри хранилища.
+type Storage interface {
+	Put(ctx context.Context, key string, body io.ReadSeeker, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Копия объекта под новым ключом без чтения объекта в память целиком
+	Copy(ctx context.Context, from, to string) error
+	// Удаление отсутствующего объекта не считается ошибкой
+	Delete(ctx context.Context, key string) error
+	// Публичный адрес объекта
+	URL(key string) string
+}
+
+// Создание хранилища по настройкам
+func New(cfg config.StorageConfig) (Storage, error) {
+	switch cfg.Driver {
+	case "s3":
+		return NewS3Storage(cfg)
+	case "", "local":
+		return NewLocalStorage(cfg.Dir, cfg.BaseURL)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
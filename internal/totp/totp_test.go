@@ -0,0 +1,107 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// Секрет из тестовых векторов RFC 6238 (SHA1): "12345678901234567890" в base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// Тестовые векторы RFC 6238, приложение B. В RFC коды из 8 цифр;
+// код из 6 цифр совпадает с их окончанием.
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestCodeRFC6238(t *testing.T) {
+	for _, tt := range rfcVectors {
+		code, err := Code(rfcSecret, Counter(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != tt.code {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, code, tt.code)
+		}
+	}
+}
+
+func TestValidateRFC6238(t *testing.T) {
+	for _, tt := range rfcVectors {
+		now := time.Unix(tt.unix, 0)
+		counter, ok := Validate(rfcSecret, tt.code, now, 0)
+		if !ok || counter != Counter(now) {
+			t.Errorf("Validate(%s) at %d = %d, %v; want %d, true", tt.code, tt.unix, counter, ok, Counter(now))
+		}
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := Counter(now)
+
+	tests := []struct {
+		name   string
+		offset int64 // Шаг кода относительно текущего
+		skew   int64
+		ok     bool
+	}{
+		{"current", 0, 1, true},
+		{"previous step", -1, 1, true},
+		{"next step", 1, 1, true},
+		{"two steps back", -2, 1, false},
+		{"two steps ahead", 2, 1, false},
+		{"previous step without skew", -1, 0, false},
+		{"next step without skew", 1, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := Code(rfcSecret, current+tt.offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			counter, ok := Validate(rfcSecret, code, now, tt.skew)
+			if ok != tt.ok {
+				t.Fatalf("Validate code of step %+d with skew %d: ok %v, want %v", tt.offset, tt.skew, ok, tt.ok)
+			}
+			// Возвращается шаг совпавшего кода, а не текущий
+			if ok && counter != current+tt.offset {
+				t.Fatalf("Validate code of step %+d: counter %d, want %d", tt.offset, counter, current+tt.offset)
+			}
+		})
+	}
+}
+
+func TestValidateInput(t *testing.T) {
+	now := time.Unix(59, 0)
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		ok     bool
+	}{
+		{"surrounding spaces", rfcSecret, " 287082\n", true},
+		{"lowercase secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "287082", true},
+		{"wrong code", rfcSecret, "287083", false},
+		{"rfc eight digits", rfcSecret, "94287082", false},
+		{"short code", rfcSecret, "28708", false},
+		{"empty code", rfcSecret, "", false},
+		{"invalid secret", "not base32!", "287082", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := Validate(tt.secret, tt.code, now, 1); ok != tt.ok {
+				t.Fatalf("Validate(%q, %q): ok %v, want %v", tt.secret, tt.code, ok, tt.ok)
+			}
+		})
+	}
+}